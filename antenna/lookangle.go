@@ -0,0 +1,121 @@
+package antenna
+
+import (
+	"math"
+	"sort"
+)
+
+// Satellite is a geostationary satellite, by the longitude it sits over (degrees east, so west is negative)
+type Satellite struct {
+	Name      string
+	Longitude float64
+}
+
+// DefaultSatellites are the satellites look angles are worked out for when the satellites block isn't set
+var DefaultSatellites = []Satellite{
+	{"GOES-East", -75.2},
+	{"GOES-West", -137.2},
+	{"GK-2A", 128.2},
+}
+
+// SatellitesFromMap turns the satellites config block, a map of names to longitudes, into a list sorted
+// by name. An empty map gives DefaultSatellites
+func SatellitesFromMap(longitudes map[string]float64) []Satellite {
+	if len(longitudes) == 0 {
+		return DefaultSatellites
+	}
+	satellites := make([]Satellite, 0, len(longitudes))
+	for name, longitude := range longitudes {
+		satellites = append(satellites, Satellite{name, longitude})
+	}
+	sort.Slice(satellites, func(i, j int) bool { return satellites[i].Name < satellites[j].Name })
+	return satellites
+}
+
+// Station is where the dish is: latitude and longitude in degrees (north and east positive), and altitude
+// in metres above the WGS84 ellipsoid
+type Station struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+// LookAngle is where to point the dish to see a satellite
+type LookAngle struct {
+	Satellite Satellite
+	// Azimuth is in degrees clockwise from true north, and Elevation in degrees above the horizon
+	Azimuth   float64
+	Elevation float64
+	// Skew is how far to rotate the LNB from upright, in degrees clockwise as seen from behind the dish
+	Skew float64
+	// Range is the distance to the satellite in km
+	Range float64
+}
+
+// Visible reports whether the satellite is above the horizon
+func (l LookAngle) Visible() bool {
+	return l.Elevation > 0
+}
+
+const (
+	// WGS84 ellipsoid
+	earthRadius     = 6378.137 // km
+	earthFlattening = 1 / 298.257223563
+	// Radius of the geostationary orbit
+	geoRadius = 42164.17 // km
+)
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// LookAngles works out the look angle from the station to each satellite
+func LookAngles(station Station, satellites []Satellite) []LookAngle {
+	angles := make([]LookAngle, len(satellites))
+	for i, satellite := range satellites {
+		angles[i] = Look(station, satellite)
+	}
+	return angles
+}
+
+// Look works out where to point the dish at the station to see the satellite, treating the satellite as
+// sitting exactly over the equator at its longitude
+func Look(station Station, satellite Satellite) LookAngle {
+	lat, lon := radians(station.Latitude), radians(station.Longitude)
+
+	// The station's position in Earth centred, Earth fixed coordinates
+	e2 := earthFlattening * (2 - earthFlattening)
+	n := earthRadius / math.Sqrt(1-e2*math.Sin(lat)*math.Sin(lat))
+	alt := station.Altitude / 1000
+	sx := (n + alt) * math.Cos(lat) * math.Cos(lon)
+	sy := (n + alt) * math.Cos(lat) * math.Sin(lon)
+	sz := (n*(1-e2) + alt) * math.Sin(lat)
+
+	satLon := radians(satellite.Longitude)
+	dx := geoRadius*math.Cos(satLon) - sx
+	dy := geoRadius*math.Sin(satLon) - sy
+	dz := -sz
+
+	// Rotate the line of sight into the station's local east, north and up
+	east := -math.Sin(lon)*dx + math.Cos(lon)*dy
+	north := -math.Sin(lat)*math.Cos(lon)*dx - math.Sin(lat)*math.Sin(lon)*dy + math.Cos(lat)*dz
+	up := math.Cos(lat)*math.Cos(lon)*dx + math.Cos(lat)*math.Sin(lon)*dy + math.Sin(lat)*dz
+
+	azimuth := degrees(math.Atan2(east, north))
+	if azimuth < 0 {
+		azimuth += 360
+	}
+
+	skew := degrees(math.Atan(math.Sin(lon-satLon) / math.Tan(lat)))
+	if math.IsNaN(skew) {
+		// On the equator, right under the satellite
+		skew = 0
+	}
+
+	return LookAngle{
+		Satellite: satellite,
+		Azimuth:   azimuth,
+		Elevation: degrees(math.Atan2(up, math.Hypot(east, north))),
+		Skew:      skew,
+		Range:     math.Sqrt(dx*dx + dy*dy + dz*dz),
+	}
+}
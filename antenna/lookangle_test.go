@@ -0,0 +1,60 @@
+package antenna
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLook(t *testing.T) {
+	goesEast := Satellite{"GOES-East", -75.2}
+	gk2a := Satellite{"GK-2A", 128.2}
+
+	// The expected angles are from the usual spherical Earth dish pointing formulas, which are within a few
+	// hundredths of a degree of the ellipsoid at these latitudes
+	tests := []struct {
+		name      string
+		station   Station
+		satellite Satellite
+		azimuth   float64
+		elevation float64
+		skew      float64
+	}{
+		{"Denver", Station{39.74, -104.99, 1609}, goesEast, 138.16, 34.72, -30.86},
+		{"Miami", Station{25.76, -80.19, 0}, goesEast, 168.64, 59.37, -10.22},
+		{"Tokyo", Station{35.68, 139.69, 0}, gk2a, 199.21, 46.81, 15.50},
+		// South of the equator the satellite is to the north, and the skew flips
+		{"Buenos Aires", Station{-34.60, -58.38, 25}, goesEast, 331.97, 45.96, -22.76},
+		{"Sydney", Station{-33.87, 151.21, 0}, gk2a, 322.69, 43.55, -30.22},
+		{"Perth", Station{-31.95, 115.86, 0}, gk2a, 22.46, 50.46, 18.92},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			look := Look(tt.station, tt.satellite)
+			if math.Abs(look.Azimuth-tt.azimuth) > 0.1 || math.Abs(look.Elevation-tt.elevation) > 0.1 || math.Abs(look.Skew-tt.skew) > 0.1 {
+				t.Errorf("Look() = az %.2f, el %.2f, skew %.2f, want %.2f, %.2f, %.2f",
+					look.Azimuth, look.Elevation, look.Skew, tt.azimuth, tt.elevation, tt.skew)
+			}
+			if !look.Visible() {
+				t.Error("satellite isn't visible")
+			}
+			if look.Range < geoRadius-earthRadius || look.Range > geoRadius {
+				t.Errorf("range of %.0f km", look.Range)
+			}
+		})
+	}
+}
+
+func TestLookUnderSatellite(t *testing.T) {
+	look := Look(Station{0, -75.2, 0}, Satellite{"GOES-East", -75.2})
+	if math.Abs(look.Elevation-90) > 1e-6 || look.Skew != 0 || math.Abs(look.Range-(geoRadius-earthRadius)) > 1e-6 {
+		t.Errorf("Look() = el %.2f, skew %.2f, range %.3f km, want straight up, no skew and %.3f km",
+			look.Elevation, look.Skew, look.Range, geoRadius-earthRadius)
+	}
+}
+
+func TestLookBelowHorizon(t *testing.T) {
+	look := Look(Station{39.74, -104.99, 1609}, Satellite{"GK-2A", 128.2})
+	if look.Visible() {
+		t.Errorf("GK-2A is visible from Denver at %.2f degrees", look.Elevation)
+	}
+}
@@ -0,0 +1,219 @@
+package antenna
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/config"
+)
+
+// RotatorPosition is where the rotator was last reported to be pointing, in degrees
+type RotatorPosition struct {
+	Azimuth   float64
+	Elevation float64
+	Time      time.Time
+	// Err is set if the last attempt to read the position failed
+	Err error
+}
+
+// Rotator is a client for hamlib's rotctld, which drives most antenna rotators over TCP. It connects on
+// the first command, and again on the next command after a connection fails. It's safe for concurrent use
+type Rotator struct {
+	conf   config.RotatorConf
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	// Held by Jog from reading the target to sending the new one, so jogs in quick succession all add up
+	// and reach rotctld in order
+	jogMutex sync.Mutex
+	// Where the rotator was last told to go, so jogs add up even before it gets there
+	target *RotatorPosition
+	latest atomic.Pointer[RotatorPosition]
+}
+
+func NewRotator(conf config.RotatorConf) *Rotator {
+	if conf.Step <= 0 {
+		conf.Step = 1
+	}
+	if conf.PollMs <= 0 {
+		conf.PollMs = 1000
+	}
+	if conf.MaxAzimuth <= conf.MinAzimuth {
+		conf.MinAzimuth, conf.MaxAzimuth = 0, 360
+	}
+	if conf.MaxElevation <= conf.MinElevation {
+		conf.MinElevation, conf.MaxElevation = 0, 90
+	}
+	return &Rotator{conf: conf}
+}
+
+// How long to wait for rotctld to answer a command
+const rotatorTimeout = 2 * time.Second
+
+// command sends a command to rotctld and returns the lines it answers with. Commands that don't return
+// anything (lines is 0) answer with a report instead, "RPRT 0" on success or a negative hamlib error code.
+// Any command can fail with a report
+func (r *Rotator) command(cmd string, lines int) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.conn == nil {
+		conn, err := net.DialTimeout("tcp", r.conf.Address, rotatorTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Could not connect to rotctld at %s: %w", r.conf.Address, err)
+		}
+		r.conn = conn
+		r.reader = bufio.NewReader(conn)
+	}
+
+	reply, err := r.exchange(cmd, lines)
+	if err != nil {
+		// Start over with a new connection next time, rather than reading the rest of this reply
+		r.conn.Close()
+		r.conn = nil
+	}
+	return reply, err
+}
+
+func (r *Rotator) exchange(cmd string, lines int) ([]string, error) {
+	r.conn.SetDeadline(time.Now().Add(rotatorTimeout))
+	if _, err := fmt.Fprintf(r.conn, "%s\n", cmd); err != nil {
+		return nil, fmt.Errorf("Could not send %q to rotctld: %w", cmd, err)
+	}
+
+	// Without any lines to read, the report is the whole reply
+	var reply []string
+	for lines == 0 || len(reply) < lines {
+		line, err := r.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Could not read rotctld's reply to %q: %w", cmd, err)
+		}
+		line = strings.TrimSpace(line)
+		if code, ok := strings.CutPrefix(line, "RPRT "); ok {
+			if code != "0" {
+				return nil, fmt.Errorf("rotctld rejected %q with error %s", cmd, code)
+			}
+			return reply, nil
+		}
+		reply = append(reply, line)
+	}
+	return reply, nil
+}
+
+// Position asks the rotator where it's pointing
+func (r *Rotator) Position() (RotatorPosition, error) {
+	reply, err := r.command("p", 2)
+	if err != nil {
+		return RotatorPosition{}, err
+	}
+	if len(reply) < 2 {
+		return RotatorPosition{}, fmt.Errorf("rotctld didn't return a position")
+	}
+
+	azimuth, err := strconv.ParseFloat(reply[0], 64)
+	if err != nil {
+		return RotatorPosition{}, fmt.Errorf("rotctld returned an invalid azimuth %q", reply[0])
+	}
+	elevation, err := strconv.ParseFloat(reply[1], 64)
+	if err != nil {
+		return RotatorPosition{}, fmt.Errorf("rotctld returned an invalid elevation %q", reply[1])
+	}
+	return RotatorPosition{Azimuth: azimuth, Elevation: elevation, Time: time.Now()}, nil
+}
+
+// SetPosition tells the rotator to point at azimuth and elevation, which are first clamped to the
+// configured limits. It returns once rotctld has taken the command, not once the rotator gets there
+func (r *Rotator) SetPosition(azimuth float64, elevation float64) error {
+	azimuth = math.Min(math.Max(azimuth, r.conf.MinAzimuth), r.conf.MaxAzimuth)
+	elevation = math.Min(math.Max(elevation, r.conf.MinElevation), r.conf.MaxElevation)
+	if _, err := r.command(fmt.Sprintf("P %.2f %.2f", azimuth, elevation), 0); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.target = &RotatorPosition{Azimuth: azimuth, Elevation: elevation, Time: time.Now()}
+	r.mutex.Unlock()
+	return nil
+}
+
+// Jog moves the rotator by a number of steps (rotator.step degrees each) in azimuth and elevation, from
+// wherever it was last told to go
+func (r *Rotator) Jog(azimuthSteps int, elevationSteps int) error {
+	r.jogMutex.Lock()
+	defer r.jogMutex.Unlock()
+
+	r.mutex.Lock()
+	target := r.target
+	r.mutex.Unlock()
+
+	if target == nil {
+		position, err := r.Position()
+		if err != nil {
+			return err
+		}
+		target = &position
+	}
+	return r.SetPosition(target.Azimuth+float64(azimuthSteps)*r.conf.Step, target.Elevation+float64(elevationSteps)*r.conf.Step)
+}
+
+// Stop stops the rotator wherever it is
+func (r *Rotator) Stop() error {
+	r.jogMutex.Lock()
+	defer r.jogMutex.Unlock()
+
+	_, err := r.command("S", 0)
+
+	r.mutex.Lock()
+	r.target = nil
+	r.mutex.Unlock()
+	return err
+}
+
+// Latest returns the position from the last time Track polled the rotator, or nil if it hasn't yet
+func (r *Rotator) Latest() *RotatorPosition {
+	return r.latest.Load()
+}
+
+// Track polls the rotator's position every rotator.poll_ms until ctx is cancelled, for Latest
+func (r *Rotator) Track(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.conf.PollMs) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		position, err := r.Position()
+		if err != nil {
+			log.Debugf("Could not read the rotator's position: %v", err)
+			// Keep showing the last good position, along with the error
+			if last := r.latest.Load(); last != nil {
+				position = *last
+			}
+			position.Err = err
+		}
+		r.latest.Store(&position)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the connection to rotctld. The rotator carries on to wherever it was last told to go
+func (r *Rotator) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
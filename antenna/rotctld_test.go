@@ -0,0 +1,270 @@
+package antenna
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jrwynneiii/goestuner/config"
+)
+
+// fakeRotctld speaks enough of rotctld's protocol to test against: p, P and S, each answered the way
+// rotctld answers them. It gets wherever it's told to go straight away
+type fakeRotctld struct {
+	listener  net.Listener
+	mutex     sync.Mutex
+	azimuth   float64
+	elevation float64
+	// Every command received, in order
+	commands []string
+	// rotctld's answer to the next P command is this error code instead
+	rejectNext string
+	// The connection is dropped instead of answering the next command
+	dropNext bool
+	// How long to take over answering each command
+	delay    time.Duration
+	accepted int
+}
+
+func newFakeRotctld(t *testing.T) *fakeRotctld {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRotctld{listener: listener, azimuth: 100, elevation: 45}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mutex.Lock()
+			f.accepted++
+			f.mutex.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRotctld) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+
+		f.mutex.Lock()
+		f.commands = append(f.commands, cmd)
+		if f.dropNext {
+			f.dropNext = false
+			f.mutex.Unlock()
+			return
+		}
+		var reply string
+		switch {
+		case cmd == "p":
+			reply = fmt.Sprintf("%.6f\n%.6f\n", f.azimuth, f.elevation)
+		case strings.HasPrefix(cmd, "P "):
+			if f.rejectNext != "" {
+				reply = "RPRT " + f.rejectNext + "\n"
+				f.rejectNext = ""
+			} else {
+				fmt.Sscanf(cmd, "P %f %f", &f.azimuth, &f.elevation)
+				reply = "RPRT 0\n"
+			}
+		case cmd == "S":
+			reply = "RPRT 0\n"
+		default:
+			reply = "RPRT -1\n"
+		}
+		delay := f.delay
+		f.mutex.Unlock()
+		time.Sleep(delay)
+		conn.Write([]byte(reply))
+	}
+}
+
+func (f *fakeRotctld) received() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func (f *fakeRotctld) rotator(conf config.RotatorConf) *Rotator {
+	conf.Address = f.listener.Addr().String()
+	return NewRotator(conf)
+}
+
+func TestRotatorPosition(t *testing.T) {
+	f := newFakeRotctld(t)
+	r := f.rotator(config.RotatorConf{})
+	defer r.Close()
+
+	position, err := r.Position()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Azimuth != 100 || position.Elevation != 45 {
+		t.Errorf("Position() = %.2f, %.2f, want 100.00, 45.00", position.Azimuth, position.Elevation)
+	}
+}
+
+func TestRotatorSetPosition(t *testing.T) {
+	tests := []struct {
+		name               string
+		conf               config.RotatorConf
+		azimuth, elevation float64
+		want               string
+	}{
+		{"within the limits", config.RotatorConf{}, 120.5, 30.25, "P 120.50 30.25"},
+		{"clamped to the default limits", config.RotatorConf{}, 400, -5, "P 360.00 0.00"},
+		{"clamped to configured limits", config.RotatorConf{MinAzimuth: 90, MaxAzimuth: 270, MinElevation: 10, MaxElevation: 80}, 45, 85, "P 90.00 80.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeRotctld(t)
+			r := f.rotator(tt.conf)
+			defer r.Close()
+
+			if err := r.SetPosition(tt.azimuth, tt.elevation); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.received(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatorRejected(t *testing.T) {
+	f := newFakeRotctld(t)
+	r := f.rotator(config.RotatorConf{})
+	defer r.Close()
+
+	f.mutex.Lock()
+	f.rejectNext = "-8"
+	f.mutex.Unlock()
+	if err := r.SetPosition(10, 10); err == nil || !strings.Contains(err.Error(), "-8") {
+		t.Errorf("SetPosition() = %v, want rotctld's error -8", err)
+	}
+	// A rejected command doesn't become the target to jog from
+	if err := r.Jog(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := f.received()
+	if want := "P 101.00 45.00"; got[len(got)-1] != want {
+		t.Errorf("jogged with %q, want %q", got[len(got)-1], want)
+	}
+}
+
+func TestRotatorJog(t *testing.T) {
+	f := newFakeRotctld(t)
+	r := f.rotator(config.RotatorConf{Step: 0.5})
+	defer r.Close()
+
+	// The first jog starts from where the rotator is, and later ones from where it was last told to go
+	if err := r.Jog(2, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Jog(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"p", "P 101.00 44.50", "P 101.50 44.50"}
+	if got := f.received(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	// Stop forgets the target, so the next jog asks where the rotator got to
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Jog(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, "S", "p", "P 101.50 45.00")
+	if got := f.received(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestRotatorConcurrentJogs(t *testing.T) {
+	f := newFakeRotctld(t)
+	f.mutex.Lock()
+	f.delay = time.Millisecond
+	f.mutex.Unlock()
+	r := f.rotator(config.RotatorConf{})
+	defer r.Close()
+
+	// Like a key held down, with every press jogging from its own goroutine
+	const presses = 20
+	var wg sync.WaitGroup
+	for i := 0; i < presses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Jog(1, 0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := f.received()
+	if want := fmt.Sprintf("P %.2f 45.00", 100.0+presses); got[len(got)-1] != want {
+		t.Errorf("last sent %q, want %q", got[len(got)-1], want)
+	}
+	for i, cmd := range got[1:] {
+		if want := fmt.Sprintf("P %.2f 45.00", 101.0+float64(i)); cmd != want {
+			t.Errorf("jog %d sent %q, want %q", i, cmd, want)
+		}
+	}
+}
+
+func TestRotatorReconnect(t *testing.T) {
+	f := newFakeRotctld(t)
+	r := f.rotator(config.RotatorConf{})
+	defer r.Close()
+
+	if _, err := r.Position(); err != nil {
+		t.Fatal(err)
+	}
+	f.mutex.Lock()
+	f.dropNext = true
+	f.mutex.Unlock()
+	if _, err := r.Position(); err == nil {
+		t.Fatal("Position() succeeded over a dropped connection")
+	}
+	if _, err := r.Position(); err != nil {
+		t.Fatalf("Position() didn't reconnect: %v", err)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.accepted != 2 {
+		t.Errorf("connected %d times, want 2", f.accepted)
+	}
+}
+
+func TestRotatorNotRunning(t *testing.T) {
+	// Nothing listening on the address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	r := NewRotator(config.RotatorConf{Address: address})
+	if _, err := r.Position(); err == nil {
+		t.Error("Position() succeeded with nothing to connect to")
+	}
+}
@@ -16,6 +16,43 @@ tui {
   vit_threshold_warn_pct = 3
   vit_threshold_crit_pct = 5
   enable_log_output = true
+  history_minutes = 5
+  pointing_metric = "snr"
+}
+
+// How the SNR is estimated, and the windows it's summarised over (as well as the whole session)
+snr {
+  estimator = "m2m4"
+  windows = ["5s", "1m"]
+  peak_decay = 0.1
+}
+
+// Where the dish is, for working out where to point it. Latitude and longitude are in degrees (north and
+// east positive), and altitude in metres
+//station {
+//  latitude = 39.74
+//  longitude = -104.99
+//  altitude = 1600
+//}
+
+// The geostationary satellites to work out look angles for, by longitude (east positive)
+satellites {
+  "GOES-East" = -75.2
+  "GOES-West" = -137.2
+  "GK-2A" = 128.2
+}
+
+// A motorised mount driven by hamlib's rotctld (e.g. `rotctld -m 1` for hamlib's dummy rotator). Angles are
+// in degrees, and step is how far each press of an arrow key jogs it
+rotator {
+  enabled = false
+  address = "localhost:4533"
+  step = 0.5
+  poll_ms = 1000
+  min_azimuth = 0
+  max_azimuth = 360
+  min_elevation = 0
+  max_elevation = 90
 }
 
 //radio  {
@@ -37,20 +74,51 @@ agc {
 }
 
 clockrecovery {
+  algorithm = "mm"
   mu = 0.5
   alpha = 0.0037
   omega_limit = 0.005
 }
 
+costas {
+  loop_bandwidth = 0.001
+  damping = 0.707
+}
+
+dcblock {
+  enabled = false
+  rate = 0.0001
+}
+
+iqcorrection {
+  enabled = false
+  rate = 0.0001
+}
+
+translate {
+  frequency = 0
+}
+
+resampler {
+  enabled = false
+  samples_per_symbol = 4.0
+  max_interpolation = 64
+}
+
+// The order the demodulator's stages are run in
+pipeline {
+  stages = ["dcblock", "iqcorrection", "spectrum", "translate", "decimator", "resampler", "agc", "rrc", "costas", "clockrecovery"]
+}
+
 xrit {
   symbol_rate = 927000
   rrc_alpha = 0.3
   rrc_taps = 31
   lowpass_transition_width = 200000
-  pll_alpha = 0.001
   decimation_factor = 1
   chunk_size = 66560
   do_fft = true
+  symbol_scale = 1.0
 }
 
 xritframe {
@@ -62,3 +130,27 @@ viterbi {
   max_errors = 500
 }
 
+
+// Channel names can be overridden per satellite. Set `satellite` to the name of one of the blocks
+// below, and any VCID listed in it replaces (or adds to) the built in names
+vcids {
+  satellite = ""
+  goes18 {
+    "17" = "Clean Long-Wave IR"
+  }
+}
+
+framedump {
+  file = ""
+  tcp_address = ""
+  unix_socket = ""
+}
+
+products {
+  enabled = false
+  output_dir = "./products"
+  image_format = "png"
+  false_color = false
+  emwin_filters = []
+  dcs_platforms = []
+}
@@ -7,27 +7,63 @@ export GOESTUNER_RADIO_FREQUENCY=1694100000
 export GOESTUNER_RADIO_SAMPLE_RATE=2048000
 export GOESTUNER_RADIO_SAMPLE_TYPE=complex64
 export GOESTUNER_RADIO_DECIMATION=1
+#export GOESTUNER_STATION_LATITUDE=39.74
+#export GOESTUNER_STATION_LONGITUDE=-104.99
+#export GOESTUNER_STATION_ALTITUDE=1600
+export GOESTUNER_ROTATOR_ENABLED=false
+export GOESTUNER_ROTATOR_ADDRESS=localhost:4533
+export GOESTUNER_ROTATOR_STEP=0.5
+export GOESTUNER_ROTATOR_POLL_MS=1000
+export GOESTUNER_ROTATOR_MIN_AZIMUTH=0
+export GOESTUNER_ROTATOR_MAX_AZIMUTH=360
+export GOESTUNER_ROTATOR_MIN_ELEVATION=0
+export GOESTUNER_ROTATOR_MAX_ELEVATION=90
 export GOESTUNER_TUI_REFRESH_MS=500
 export GOESTUNER_TUI_RS_THRESHOLD_WARN_PCT=20
 export GOESTUNER_TUI_RS_THRESHOLD_CRIT_PCT=25
 export GOESTUNER_TUI_VIT_THRESHOLD_WARN_PCT=10
 export GOESTUNER_TUI_VIT_THRESHOLD_CRIT_PCT=15
 export GOESTUNER_TUI_ENABLE_LOG_OUTPUT=true
+export GOESTUNER_TUI_HISTORY_MINUTES=5
+export GOESTUNER_TUI_POINTING_METRIC=snr
+export GOESTUNER_SNR_ESTIMATOR=m2m4
+export GOESTUNER_SNR_WINDOWS="5s,1m"
+export GOESTUNER_SNR_PEAK_DECAY=0.1
 export GOESTUNER_AGC_RATE=0.01
 export GOESTUNER_AGC_REFERENCE=0.5
 export GOESTUNER_AGC_GAIN=1.0
 export GOESTUNER_AGC_MAX_GAIN=4000
+export GOESTUNER_CLOCKRECOVERY_ALGORITHM=mm
 export GOESTUNER_CLOCKRECOVERY_MU=0.5
 export GOESTUNER_CLOCKRECOVERY_ALPHA=0.0037
 export GOESTUNER_CLOCKRECOVERY_OMEGA_LIMIT=0.005
+export GOESTUNER_COSTAS_LOOP_BANDWIDTH=0.001
+export GOESTUNER_COSTAS_DAMPING=0.707
+export GOESTUNER_DCBLOCK_ENABLED=false
+export GOESTUNER_DCBLOCK_RATE=0.0001
+export GOESTUNER_IQCORRECTION_ENABLED=false
+export GOESTUNER_IQCORRECTION_RATE=0.0001
+export GOESTUNER_RESAMPLER_ENABLED=false
+export GOESTUNER_RESAMPLER_SAMPLES_PER_SYMBOL=4.0
+export GOESTUNER_RESAMPLER_MAX_INTERPOLATION=64
+export GOESTUNER_TRANSLATE_FREQUENCY=0
+export GOESTUNER_PIPELINE_STAGES="dcblock,iqcorrection,spectrum,translate,decimator,resampler,agc,rrc,costas,clockrecovery"
 export GOESTUNER_XRIT_SYMBOL_RATE=927000
 export GOESTUNER_XRIT_RRC_ALPHA=0.3
 export GOESTUNER_XRIT_RRC_TAPS=31
 export GOESTUNER_XRIT_LOWPASS_TRANSITION_WIDTH=200000
-export GOESTUNER_XRIT_PLL_ALPHA=0.001
 export GOESTUNER_XRIT_DECIMATION_FACTOR=1
 export GOESTUNER_XRIT_CHUNK_SIZE=66560
 export GOESTUNER_XRIT_DO_FFT=false
+export GOESTUNER_XRIT_SYMBOL_SCALE=1.0
 export GOESTUNER_XRITFRAME_FRAME_SIZE=1024
 export GOESTUNER_XRITFRAME_LAST_FRAME_SIZE=8
 export GOESTUNER_VITERBI_MAX_ERRORS=500
+export GOESTUNER_PRODUCTS_ENABLED=false
+export GOESTUNER_PRODUCTS_OUTPUT_DIR=./products
+export GOESTUNER_PRODUCTS_IMAGE_FORMAT=png
+export GOESTUNER_PRODUCTS_FALSE_COLOR=false
+export GOESTUNER_FRAMEDUMP_FILE=
+export GOESTUNER_FRAMEDUMP_TCP_ADDRESS=
+export GOESTUNER_FRAMEDUMP_UNIX_SOCKET=
+export GOESTUNER_VCIDS_SATELLITE=
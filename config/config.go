@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type RadioConf struct {
 	Address     string  `koanf:"address"`
 	DeviceIndex int     `koanf:"device_index"`
@@ -18,11 +20,60 @@ type AGCConf struct {
 }
 
 type ClockRecoveryConf struct {
+	Algorithm  string  `koanf:"algorithm"`
 	Mu         float32 `koanf:"mu"`
 	Alpha      float32 `koanf:"alpha"`
 	OmegaLimit float32 `koanf:"omega_limit"`
 }
 
+type CostasConf struct {
+	LoopBandwidth float32 `koanf:"loop_bandwidth"`
+	Damping       float32 `koanf:"damping"`
+}
+
+type DCBlockConf struct {
+	Enabled bool    `koanf:"enabled"`
+	Rate    float32 `koanf:"rate"`
+}
+
+type IQCorrectionConf struct {
+	Enabled bool    `koanf:"enabled"`
+	Rate    float32 `koanf:"rate"`
+}
+
+type TranslateConf struct {
+	Frequency float32 `koanf:"frequency"`
+}
+
+type ResamplerConf struct {
+	Enabled          bool    `koanf:"enabled"`
+	SamplesPerSymbol float64 `koanf:"samples_per_symbol"`
+	MaxInterpolation int     `koanf:"max_interpolation"`
+}
+
+type SNRConf struct {
+	Estimator string          `koanf:"estimator"`
+	Windows   []time.Duration `koanf:"windows"`
+	PeakDecay float64         `koanf:"peak_decay"`
+}
+
+type StationConf struct {
+	Latitude  float64 `koanf:"latitude"`
+	Longitude float64 `koanf:"longitude"`
+	Altitude  float64 `koanf:"altitude"`
+}
+
+type RotatorConf struct {
+	Enabled      bool    `koanf:"enabled"`
+	Address      string  `koanf:"address"`
+	Step         float64 `koanf:"step"`
+	PollMs       int     `koanf:"poll_ms"`
+	MinAzimuth   float64 `koanf:"min_azimuth"`
+	MaxAzimuth   float64 `koanf:"max_azimuth"`
+	MinElevation float64 `koanf:"min_elevation"`
+	MaxElevation float64 `koanf:"max_elevation"`
+}
+
 type XRITConf struct {
 	SymbolRate             float64 `koanf:"symbol_rate"`
 	RRCAlpha               float64 `koanf:"rrc_alpha"`
@@ -32,6 +83,7 @@ type XRITConf struct {
 	Decimation             int     `koanf:"decimation_factor"`
 	ChunkSize              uint    `koanf:"chunk_size"`
 	DoFFT                  bool    `koanf:"do_fft"`
+	SymbolScale            float32 `koanf:"symbol_scale"`
 }
 
 type XRITFrameConf struct {
@@ -39,6 +91,17 @@ type XRITFrameConf struct {
 	LastFrameSize int `koanf:"last_frame_size"`
 }
 
+type FrameDumpConf struct {
+	File       string `koanf:"file"`
+	TCPAddress string `koanf:"tcp_address"`
+	UnixSocket string `koanf:"unix_socket"`
+}
+
+type VCIDConf struct {
+	Satellite string            `koanf:"satellite"`
+	Names     map[string]string `koanf:"-"`
+}
+
 type ViterbiConf struct {
 	MaxErrors int `koanf:"max_errors"`
 }
@@ -50,4 +113,15 @@ type TuiConf struct {
 	VitWarnPct      float64 `koanf:"vit_threshold_warn_pct"`
 	VitCritPct      float64 `koanf:"vit_threshold_crit_pct"`
 	EnableLogOutput bool    `koanf:"enable_log_output"`
+	HistoryMinutes  int     `koanf:"history_minutes"`
+	PointingMetric  string  `koanf:"pointing_metric"`
+}
+
+type ProductsConf struct {
+	Enabled      bool     `koanf:"enabled"`
+	OutputDir    string   `koanf:"output_dir"`
+	ImageFormat  string   `koanf:"image_format"`
+	FalseColor   bool     `koanf:"false_color"`
+	EMWINFilters []string `koanf:"emwin_filters"`
+	DCSPlatforms []string `koanf:"dcs_platforms"`
 }
@@ -0,0 +1,76 @@
+package datalink
+
+import (
+	"time"
+)
+
+// Window that per-channel throughput is averaged over
+const ThroughputWindow = 10 * time.Second
+
+// ChannelStats tracks what the decoder has received on a single virtual channel
+type ChannelStats struct {
+	Received        int
+	Dropped         int
+	Bytes           int64
+	LastSeen        time.Time
+	FramesPerSecond float64
+	arrivals        []time.Time
+}
+
+// DropRate returns the percentage of frames on this channel that could not be corrected
+func (c ChannelStats) DropRate() float64 {
+	total := c.Received + c.Dropped
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(c.Dropped) / float64(total)
+}
+
+func (c *ChannelStats) addFrame(now time.Time, size int) {
+	c.Received++
+	c.Bytes += int64(size)
+	c.LastSeen = now
+	c.arrivals = append(c.arrivals, now)
+	c.prune(now)
+}
+
+// prune forgets arrivals that have fallen out of the throughput window
+func (c *ChannelStats) prune(now time.Time) {
+	cutoff := now.Add(-ThroughputWindow)
+	i := 0
+	for i < len(c.arrivals) && c.arrivals[i].Before(cutoff) {
+		i++
+	}
+	c.arrivals = c.arrivals[i:]
+}
+
+// snapshot returns a copy of the stats with the current throughput filled in
+func (c *ChannelStats) snapshot(now time.Time) ChannelStats {
+	c.prune(now)
+	s := *c
+	s.FramesPerSecond = float64(len(c.arrivals)) / ThroughputWindow.Seconds()
+	s.arrivals = nil
+	return s
+}
+
+func (d *Decoder) channel(vcid int) *ChannelStats {
+	c, ok := d.Channels[vcid]
+	if !ok {
+		c = &ChannelStats{}
+		d.Channels[vcid] = c
+	}
+	return c
+}
+
+// ChannelSnapshot returns a copy of the stats for every channel we've seen frames on
+func (d *Decoder) ChannelSnapshot() map[int]ChannelStats {
+	d.StatsMutex.Lock()
+	defer d.StatsMutex.Unlock()
+
+	now := time.Now()
+	snap := make(map[int]ChannelStats, len(d.Channels))
+	for vcid, c := range d.Channels {
+		snap[vcid] = c.snapshot(now)
+	}
+	return snap
+}
@@ -0,0 +1,38 @@
+package datalink
+
+// The decoder's forward error correction and frame sync are done either by libsathelper (the default),
+// or by the pure Go implementations in this package when built with the purego tag:
+//
+//	go install -tags purego
+
+// ViterbiDecoder decodes the CCSDS rate 1/2, K=7 convolutional code
+type ViterbiDecoder interface {
+	// Decode decodes input (two soft symbols per bit) into output, packed MSB first
+	Decode(input []byte, output []byte)
+	// GetBER returns the number of bits corrected in the last frame
+	GetBER() int
+	// GetPercentBER returns the bits corrected in the last frame as a percentage of the frame
+	GetPercentBER() float32
+}
+
+// ReedSolomonDecoder corrects interleaved CCSDS (255,223) Reed-Solomon codewords
+type ReedSolomonDecoder interface {
+	// Deinterleave copies the codeword at pos out of a frame interleaved to depth n
+	Deinterleave(data []byte, output []byte, pos byte, n byte)
+	// DecodeCCSDS corrects a dual basis codeword in place, returning the number of bytes corrected,
+	// parity included, or -1 if it couldn't be corrected
+	DecodeCCSDS(data []byte) int
+	// Interleave copies a codeword, parity included, back into a frame interleaved to depth n
+	Interleave(data []byte, output []byte, pos byte, n byte)
+}
+
+// SyncCorrelator looks for the best match of a set of sync words in a run of soft symbols
+type SyncCorrelator interface {
+	AddWord(word uint64)
+	Correlate(data []byte)
+	GetHighestCorrelation() uint
+	GetHighestCorrelationPosition() uint
+	// GetCorrelationWordNumber returns the index of the word that matched best, in the order the
+	// words were added
+	GetCorrelationWordNumber() uint
+}
@@ -0,0 +1,23 @@
+//go:build purego
+
+package datalink
+
+func newViterbi(frameBits int) ViterbiDecoder {
+	return NewViterbi27(frameBits)
+}
+
+func newReedSolomon() ReedSolomonDecoder {
+	return NewReedSolomon()
+}
+
+func newCorrelator() SyncCorrelator {
+	return NewCorrelator()
+}
+
+func nrzmDecode(data []byte) {
+	NRZMDecode(data)
+}
+
+func derandomize(data []byte) {
+	DeRandomize(data)
+}
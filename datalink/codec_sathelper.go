@@ -0,0 +1,68 @@
+//go:build !purego
+
+package datalink
+
+import (
+	SatHelper "github.com/opensatelliteproject/libsathelper"
+)
+
+// The libsathelper types take raw pointers, so these wrap them up to take slices instead
+
+type satHelperViterbi struct {
+	SatHelper.Viterbi27
+}
+
+func (v satHelperViterbi) Decode(input []byte, output []byte) {
+	v.Viterbi27.Decode(&input[0], &output[0])
+}
+
+type satHelperReedSolomon struct {
+	SatHelper.ReedSolomon
+}
+
+func (r satHelperReedSolomon) Deinterleave(data []byte, output []byte, pos byte, n byte) {
+	r.ReedSolomon.Deinterleave(&data[0], &output[0], pos, n)
+}
+
+func (r satHelperReedSolomon) DecodeCCSDS(data []byte) int {
+	// Uncorrectable codewords come back as a uint32 -1
+	return int(int32(r.ReedSolomon.Decode_ccsds(&data[0])))
+}
+
+func (r satHelperReedSolomon) Interleave(data []byte, output []byte, pos byte, n byte) {
+	r.ReedSolomon.Interleave(&data[0], &output[0], pos, n)
+}
+
+type satHelperCorrelator struct {
+	SatHelper.Correlator
+}
+
+func (c satHelperCorrelator) AddWord(word uint64) {
+	c.Correlator.AddWord(word)
+}
+
+func (c satHelperCorrelator) Correlate(data []byte) {
+	c.Correlator.Correlate(&data[0], uint(len(data)))
+}
+
+func newViterbi(frameBits int) ViterbiDecoder {
+	return satHelperViterbi{SatHelper.NewViterbi27(frameBits)}
+}
+
+func newReedSolomon() ReedSolomonDecoder {
+	rs := SatHelper.NewReedSolomon()
+	rs.SetCopyParityToOutput(true)
+	return satHelperReedSolomon{rs}
+}
+
+func newCorrelator() SyncCorrelator {
+	return satHelperCorrelator{SatHelper.NewCorrelator()}
+}
+
+func nrzmDecode(data []byte) {
+	SatHelper.DifferentialEncodingNrzmDecode(&data[0], len(data))
+}
+
+func derandomize(data []byte) {
+	SatHelper.DeRandomizerDeRandomize(&data[0], len(data))
+}
@@ -0,0 +1,129 @@
+//go:build !purego
+
+package datalink
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// These run libsathelper and the pure Go implementations side by side on the same frames, so the purego
+// build can't drift from the default one. The frames are noisy encodings of random data, seeded so every
+// run sees the same ones
+
+const crossCheckFrames = 20
+
+// noisySymbols encodes a frame into soft symbols with Gaussian noise, sigma being relative to the
+// distance between the two symbol levels
+func noisySymbols(rng *rand.Rand, frame []byte, sigma float64) []byte {
+	v := NewViterbi27(len(frame) * 8)
+	symbols := make([]byte, len(frame)*8*2)
+	v.encode(frame, symbols)
+	for i, s := range symbols {
+		noisy := float64(s) + rng.NormFloat64()*sigma*255
+		symbols[i] = byte(min(max(noisy, 0), 255))
+	}
+	return symbols
+}
+
+func TestCrossCheckViterbi(t *testing.T) {
+	const frameSize = 1024
+	rng := rand.New(rand.NewPCG(34, 1))
+	goDecoder := NewViterbi27(frameSize * 8)
+	libDecoder := newViterbi(frameSize * 8)
+	for n := 0; n < crossCheckFrames; n++ {
+		frame := testFrame(rng, frameSize)
+		// From clean up to about as noisy as a frame can be and still decode. Past that, where the two
+		// give up on a frame differs
+		symbols := noisySymbols(rng, frame, 0.3*float64(n)/crossCheckFrames)
+
+		goDecoded := make([]byte, frameSize)
+		libDecoded := make([]byte, frameSize)
+		goDecoder.Decode(bytes.Clone(symbols), goDecoded)
+		libDecoder.Decode(bytes.Clone(symbols), libDecoded)
+		if !bytes.Equal(goDecoded, libDecoded) {
+			t.Errorf("frame %d: decoded frames differ", n)
+		}
+		if goDecoder.GetBER() != libDecoder.GetBER() {
+			t.Errorf("frame %d: GetBER() = %d, libsathelper says %d", n, goDecoder.GetBER(), libDecoder.GetBER())
+		}
+	}
+}
+
+func TestCrossCheckReedSolomon(t *testing.T) {
+	rng := rand.New(rand.NewPCG(34, 2))
+	goDecoder := NewReedSolomon()
+	libDecoder := newReedSolomon()
+	for n := 0; n < crossCheckFrames; n++ {
+		data := make([]byte, rsDataSize)
+		for i := range data {
+			data[i] = byte(rng.UintN(256))
+		}
+		codeword := rsEncode(data)
+		// From none up to past what the code can correct, anywhere in the codeword
+		for _, pos := range rng.Perm(rsBlockSize)[:n] {
+			codeword[pos] ^= byte(1 + rng.UintN(255))
+		}
+
+		goCodeword := bytes.Clone(codeword)
+		libCodeword := bytes.Clone(codeword)
+		goFixed := goDecoder.DecodeCCSDS(goCodeword)
+		libFixed := libDecoder.DecodeCCSDS(libCodeword)
+		if goFixed != libFixed {
+			t.Errorf("codeword %d: DecodeCCSDS() = %d, libsathelper says %d", n, goFixed, libFixed)
+		}
+		if goFixed >= 0 && !bytes.Equal(goCodeword, libCodeword) {
+			t.Errorf("codeword %d: corrected codewords differ", n)
+		}
+	}
+}
+
+func TestCrossCheckCorrelator(t *testing.T) {
+	rng := rand.New(rand.NewPCG(34, 3))
+	goCorrelator := NewCorrelator()
+	libCorrelator := newCorrelator()
+	for _, c := range []SyncCorrelator{goCorrelator, libCorrelator} {
+		c.AddWord(syncWord0)
+		c.AddWord(syncWord180)
+	}
+	for n := 0; n < crossCheckFrames; n++ {
+		data := noisySymbols(rng, testFrame(rng, 128), 0.3)
+		word := syncWord0
+		if n%2 == 1 {
+			word = syncWord180
+		}
+		copy(data[rng.IntN(len(data)-64):], syncSymbols(word))
+
+		goCorrelator.Correlate(data)
+		libCorrelator.Correlate(data)
+		if goCorrelator.GetCorrelationWordNumber() != libCorrelator.GetCorrelationWordNumber() ||
+			goCorrelator.GetHighestCorrelation() != libCorrelator.GetHighestCorrelation() ||
+			goCorrelator.GetHighestCorrelationPosition() != libCorrelator.GetHighestCorrelationPosition() {
+			t.Errorf("frame %d: matched word %d with %d bits at %d, libsathelper matched word %d with %d bits at %d", n,
+				goCorrelator.GetCorrelationWordNumber(), goCorrelator.GetHighestCorrelation(), goCorrelator.GetHighestCorrelationPosition(),
+				libCorrelator.GetCorrelationWordNumber(), libCorrelator.GetHighestCorrelation(), libCorrelator.GetHighestCorrelationPosition())
+		}
+	}
+}
+
+func TestCrossCheckScrambling(t *testing.T) {
+	rng := rand.New(rand.NewPCG(34, 4))
+	for n := 0; n < crossCheckFrames; n++ {
+		frame := testFrame(rng, 1024)
+
+		goFrame, libFrame := bytes.Clone(frame), bytes.Clone(frame)
+		NRZMDecode(goFrame)
+		nrzmDecode(libFrame)
+		if !bytes.Equal(goFrame, libFrame) {
+			t.Errorf("frame %d: NRZ-M decoded frames differ", n)
+		}
+
+		goFrame, libFrame = bytes.Clone(frame), bytes.Clone(frame)
+		DeRandomize(goFrame)
+		derandomize(libFrame)
+		if !bytes.Equal(goFrame, libFrame) {
+			t.Errorf("frame %d: derandomized frames differ", n)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package datalink
+
+// Correlator is a pure Go port of libsathelper's correlator. It slides each sync word along the soft
+// symbols, counting hard decision matches, and remembers where each word matched best
+type Correlator struct {
+	words       [][]byte
+	correlation []uint
+	position    []uint
+	wordNumber  uint
+}
+
+func NewCorrelator() *Correlator {
+	return &Correlator{}
+}
+
+// AddWord adds a 64 bit sync word to look for
+func (c *Correlator) AddWord(word uint64) {
+	w := make([]byte, 64)
+	for i := range w {
+		if (word>>(63-i))&1 != 0 {
+			w[i] = 0xFF
+		}
+	}
+	c.words = append(c.words, w)
+	c.correlation = append(c.correlation, 0)
+	c.position = append(c.position, 0)
+}
+
+// hardCorrelate is libsathelper's symbol comparison, which the sync words and BER were worked out against
+func hardCorrelate(data byte, word byte) bool {
+	return (data >= 127 && word == 0) || (data < 127 && word == 255)
+}
+
+func (c *Correlator) Correlate(data []byte) {
+	if len(c.words) == 0 {
+		return
+	}
+	for n := range c.words {
+		c.correlation[n] = 0
+		c.position[n] = 0
+	}
+
+	wordSize := len(c.words[0])
+	for i := 0; i < len(data)-wordSize; i++ {
+		for n, word := range c.words {
+			var corr uint
+			for k, w := range word {
+				if hardCorrelate(data[i+k], w) {
+					corr++
+				}
+			}
+			if corr > c.correlation[n] {
+				c.correlation[n] = corr
+				c.position[n] = uint(i)
+			}
+		}
+	}
+
+	var best uint
+	for n, corr := range c.correlation {
+		if corr > best {
+			c.wordNumber = uint(n)
+			best = corr
+		}
+	}
+}
+
+func (c *Correlator) GetHighestCorrelation() uint {
+	return c.correlation[c.wordNumber]
+}
+
+func (c *Correlator) GetHighestCorrelationPosition() uint {
+	return c.position[c.wordNumber]
+}
+
+func (c *Correlator) GetCorrelationWordNumber() uint {
+	return c.wordNumber
+}
@@ -0,0 +1,68 @@
+package datalink
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// syncSymbols returns the soft symbols the correlator takes as a full match for a sync word. Like
+// libsathelper's, it matches a 1 in the word against a symbol below 127
+func syncSymbols(word uint64) []byte {
+	symbols := make([]byte, 64)
+	for i := range symbols {
+		if (word>>(63-i))&1 == 0 {
+			symbols[i] = 0xFF
+		}
+	}
+	return symbols
+}
+
+func TestCorrelator(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     uint64
+		position int
+		// Symbols of the sync word to flip
+		errors     []int
+		wantNumber uint
+	}{
+		{"in phase", syncWord0, 300, nil, 0},
+		{"inverted", syncWord180, 300, nil, 1},
+		{"at the start", syncWord0, 0, nil, 0},
+		{"in phase with errors", syncWord0, 1000, []int{3, 20, 41}, 0},
+		{"inverted with errors", syncWord180, 1000, []int{0, 63}, 1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(34, uint64(i)))
+			data := make([]byte, 2048)
+			for j := range data {
+				data[j] = byte(rng.UintN(256))
+			}
+			word := syncSymbols(tt.word)
+			for _, e := range tt.errors {
+				word[e] ^= 0xFF
+			}
+			copy(data[tt.position:], word)
+
+			c := NewCorrelator()
+			c.AddWord(syncWord0)
+			c.AddWord(syncWord180)
+			c.Correlate(data)
+			if got := c.GetCorrelationWordNumber(); got != tt.wantNumber {
+				t.Errorf("GetCorrelationWordNumber() = %d, want %d", got, tt.wantNumber)
+			}
+			if got := c.GetHighestCorrelationPosition(); got != uint(tt.position) {
+				t.Errorf("GetHighestCorrelationPosition() = %d, want %d", got, tt.position)
+			}
+			if got, want := c.GetHighestCorrelation(), uint(64-len(tt.errors)); got != want {
+				t.Errorf("GetHighestCorrelation() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCorrelatorWithoutWords(t *testing.T) {
+	// Nothing to look for shouldn't panic
+	NewCorrelator().Correlate(make([]byte, 128))
+}
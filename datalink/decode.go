@@ -1,14 +1,17 @@
 package datalink
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/jrwynneiii/goestuner/config"
+	"github.com/jrwynneiii/goestuner/queue"
 	"github.com/knadh/koanf/v2"
-	SatHelper "github.com/opensatelliteproject/libsathelper"
 )
 
 var VCIDs = map[int]string{
@@ -37,50 +40,137 @@ var VCIDs = map[int]string{
 	63: "IDLE",
 }
 
+// VCIDName returns the name of a virtual channel, or "Unknown" for channels we don't have a name for
+func VCIDName(vcid int) string {
+	if name, ok := VCIDs[vcid]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// loadVCIDNames overrides the default channel names with the ones configured for the selected
+// satellite, since each satellite (and NOAA, over time) uses the VCIDs a little differently
+func loadVCIDNames(configFile *koanf.Koanf) {
+	vcidConf := config.VCIDConf{
+		Satellite: configFile.String("vcids.satellite"),
+	}
+	if vcidConf.Satellite == "" {
+		return
+	}
+	vcidConf.Names = configFile.StringMap("vcids." + vcidConf.Satellite)
+
+	log.Debugf("Found vcids definition: %##v", vcidConf)
+
+	for id, name := range vcidConf.Names {
+		vcid, err := strconv.Atoi(id)
+		if err != nil || vcid < 0 || vcid > 63 {
+			log.Warnf("Ignoring name for invalid VCID %q in vcids.%s", id, vcidConf.Satellite)
+			continue
+		}
+		VCIDs[vcid] = name
+	}
+}
+
+// The encoded sync word as seen when the Costas loop locks in phase, and when it locks 180 degrees out.
+// BPSK can't tell the two apart, so we look for both
+const (
+	syncWord0   uint64 = 0xfc4ef4fd0cc2df89
+	syncWord180 uint64 = 0x25010b02f33d2076
+)
+
 type Decoder struct {
-	TotalFramesProcessed     int
-	RxPacketsPerChannel      map[int]int
-	DroppedPacketsPerChannel map[int]int
-	StatsMutex               sync.RWMutex
-	FrameLock                bool
-	SymbolsInput             chan byte
-	MaxVitErrors             int
-	ViterbiBytes             []byte
-	DecodedBytes             []byte
-	LastFrameSizeBits        int
-	LastFrameSizeBytes       int
-	LastFrameEnd             []byte
-	Viterbi                  SatHelper.Viterbi27
-	EncodedBytes             []byte
-	RSCorrectedBytes         int64
-	ReedSolomon              SatHelper.ReedSolomon
-	Correlator               SatHelper.Correlator
-	PacketFixer              SatHelper.PacketFixer
-	SyncWord                 []byte
-	EncodedFrameSize         int
-	MaxRecheckThreshold      int
-	MinCorrelationBits       uint
-	FrameSize                int
-	SyncWordSize             int
-	RsBlocks                 byte
-	RSWorkBuffer             []byte
-	RSCorrectedData          []byte
-	RSParityBlockSize        int
-	RSParitySize             int
-	RSTotalProcessedBytes    int64
-	AverageRsCorrections     float64
-	AvgVitCorrections        float32
-	SigQuality               float32
+	TotalFramesProcessed  int
+	Channels              map[int]*ChannelStats
+	StatsMutex            sync.RWMutex
+	FrameLock             bool
+	SymbolsInput          chan []byte
+	FramesOutputs         []*FrameOutput
+	MaxVitErrors          int
+	ViterbiBytes          []byte
+	DecodedBytes          []byte
+	LastFrameSizeBits     int
+	LastFrameSizeBytes    int
+	LastFrameEnd          []byte
+	Viterbi               ViterbiDecoder
+	EncodedBytes          []byte
+	RSCorrectedBytes      int64
+	ReedSolomon           ReedSolomonDecoder
+	Correlator            SyncCorrelator
+	SyncWord              []byte
+	EncodedFrameSize      int
+	MaxRecheckThreshold   int
+	MinCorrelationBits    uint
+	FrameSize             int
+	VCDUSize              int
+	SyncWordSize          int
+	RsBlocks              byte
+	RSWorkBuffer          []byte
+	RSCorrectedData       []byte
+	RSParityBlockSize     int
+	RSParitySize          int
+	RSTotalProcessedBytes int64
+	AverageRsCorrections  float64
+	AvgVitCorrections     float32
+	SigQuality            float32
+	PhaseInverted         bool
+	PhaseFlips            int
 
 	lastFrameOk         bool
 	recheckCounter      int
 	currentFrameCorrupt bool
+	// What's left of the last batch of symbols from the demodulator
+	pendingSymbols []byte
+	// Held by Start while it works on a frame, and by Reset
+	workMutex sync.Mutex
+	// Bumped by every Reset, so a frame that was being read at the time is thrown away
+	resets int
+	stats  atomic.Pointer[DecoderStats]
+}
+
+// FrameOutput is a consumer of the decoded VCDUs, e.g. the transport layer or the frame publisher
+type FrameOutput struct {
+	Name   string
+	Frames *chan []byte
+	// Lossy consumers have frames dropped when they fall behind, rather than holding up the decoder. It's
+	// only for optional consumers like the frame publisher; the transport layer loses a whole file for
+	// every VCDU it misses
+	Lossy bool
+	// Counts the frames handed to the consumer, and dropped because it was behind
+	Stats queue.Stats
+	// When we last warned about dropped frames, and how many had been dropped by then
+	lastWarning time.Time
+	warnedDrops int64
+}
+
+// How often to warn that a lossy frame consumer is dropping frames
+const frameDropWarningInterval = 10 * time.Second
+
+// warnDropped logs how many frames the consumer has dropped, at most once every frameDropWarningInterval
+// so a stalled consumer doesn't flood the log
+func (o *FrameOutput) warnDropped(now time.Time) {
+	if now.Sub(o.lastWarning) < frameDropWarningInterval {
+		return
+	}
+	dropped := o.Stats.Dropped()
+	log.Warnf("[Data-Link] Frame consumer %s is falling behind, dropped %d frames (%d in all)", o.Name, dropped-o.warnedDrops, dropped)
+	o.lastWarning = now
+	o.warnedDrops = dropped
 }
 
+// Returned when the decoder is stopped or reset partway through reading a frame
+var (
+	errStopped = fmt.Errorf("Decoder stopped")
+	errReset   = fmt.Errorf("Decoder reset")
+)
+
+// Close closes every frame output, so the frame consumers finish off what they have queued and stop
 func (d *Decoder) Close() {
+	for _, output := range d.FramesOutputs {
+		close(*output.Frames)
+	}
 }
 
-func New(bufsize uint, configFile *koanf.Koanf) *Decoder {
+func New(bufsize uint, configFile *koanf.Koanf, framesOutputs []*FrameOutput) *Decoder {
 	vitConf := config.ViterbiConf{
 		MaxErrors: configFile.Int("viterbi.max_errors"),
 	}
@@ -89,80 +179,127 @@ func New(bufsize uint, configFile *koanf.Koanf) *Decoder {
 		LastFrameSize: configFile.Int("xritframe.last_frame_size"),
 	}
 
+	loadVCIDNames(configFile)
+
 	frameSizeBits := xritConf.FrameSize * 8
 	encodedFrameSize := frameSizeBits * 2
 	LastFrameSizeBits := xritConf.LastFrameSize * 8
 
 	d := Decoder{
-		TotalFramesProcessed:     0,
-		RxPacketsPerChannel:      make(map[int]int),
-		DroppedPacketsPerChannel: make(map[int]int),
-		FrameLock:                false,
-		SymbolsInput:             make(chan byte, bufsize),
-		ViterbiBytes:             make([]byte, encodedFrameSize+LastFrameSizeBits),
-		DecodedBytes:             make([]byte, xritConf.FrameSize+xritConf.LastFrameSize), //?
-		LastFrameEnd:             make([]byte, LastFrameSizeBits),
-		EncodedBytes:             make([]byte, encodedFrameSize),
-		SyncWord:                 make([]byte, 4),
-		RSWorkBuffer:             make([]byte, 255),
-		RSCorrectedData:          make([]byte, xritConf.FrameSize),
-		Viterbi:                  SatHelper.NewViterbi27(frameSizeBits + LastFrameSizeBits),
-		MaxVitErrors:             vitConf.MaxErrors,
-		LastFrameSizeBits:        LastFrameSizeBits,
-		LastFrameSizeBytes:       xritConf.LastFrameSize,
-		ReedSolomon:              SatHelper.NewReedSolomon(),
-		Correlator:               SatHelper.NewCorrelator(),
-		PacketFixer:              SatHelper.NewPacketFixer(),
-		EncodedFrameSize:         encodedFrameSize,
-		MaxRecheckThreshold:      100,
-		MinCorrelationBits:       46,
-		FrameSize:                xritConf.FrameSize,
-		SyncWordSize:             4,
-		RsBlocks:                 4,
-		RSParityBlockSize:        32 * 4,
-		RSParitySize:             32,
-		AverageRsCorrections:     0.0,
-		AvgVitCorrections:        0.0,
-		lastFrameOk:              false,
-		recheckCounter:           0,
-		currentFrameCorrupt:      false,
+		TotalFramesProcessed: 0,
+		Channels:             make(map[int]*ChannelStats),
+		FrameLock:            false,
+		SymbolsInput:         make(chan []byte, bufsize),
+		FramesOutputs:        framesOutputs,
+		ViterbiBytes:         make([]byte, 2*(frameSizeBits+LastFrameSizeBits)),
+		DecodedBytes:         make([]byte, xritConf.FrameSize+xritConf.LastFrameSize), //?
+		LastFrameEnd:         make([]byte, LastFrameSizeBits),
+		EncodedBytes:         make([]byte, encodedFrameSize),
+		SyncWord:             make([]byte, 4),
+		RSWorkBuffer:         make([]byte, 255),
+		RSCorrectedData:      make([]byte, xritConf.FrameSize),
+		Viterbi:              newViterbi(frameSizeBits + LastFrameSizeBits),
+		MaxVitErrors:         vitConf.MaxErrors,
+		LastFrameSizeBits:    LastFrameSizeBits,
+		LastFrameSizeBytes:   xritConf.LastFrameSize,
+		ReedSolomon:          newReedSolomon(),
+		Correlator:           newCorrelator(),
+		EncodedFrameSize:     encodedFrameSize,
+		MaxRecheckThreshold:  100,
+		MinCorrelationBits:   46,
+		FrameSize:            xritConf.FrameSize,
+		VCDUSize:             xritConf.FrameSize - 4 - 32*4,
+		SyncWordSize:         4,
+		RsBlocks:             4,
+		RSParityBlockSize:    32 * 4,
+		RSParitySize:         32,
+		AverageRsCorrections: 0.0,
+		AvgVitCorrections:    0.0,
+		lastFrameOk:          false,
+		recheckCounter:       0,
+		currentFrameCorrupt:  false,
 	}
 
-	for i := 0; i < d.LastFrameSizeBits; i++ {
-		d.LastFrameEnd[i] = 128
-	}
+	d.clearLastFrameEnd()
 
-	//Configure the ReedSolomon error corrector
-	d.ReedSolomon.SetCopyParityToOutput(true)
+	// The viterbi decoder reads two symbols for every bit it outputs, which runs past the end of the
+	// symbols we copy in. Pad that with erasures so it doesn't bias the end of the frame
+	for i := encodedFrameSize + LastFrameSizeBits; i < len(d.ViterbiBytes); i++ {
+		d.ViterbiBytes[i] = 128
+	}
 
-	// Prime the correlator
-	d.Correlator.AddWord(uint64(0xfc4ef4fd0cc2df89))
-	d.Correlator.AddWord(uint64(0x25010b02f33d2076))
+	// Prime the correlator. The order matters: the index of the word that matched tells us which phase
+	// the Costas loop locked on to
+	d.Correlator.AddWord(syncWord0)
+	d.Correlator.AddWord(syncWord180)
 
 	return &d
 }
 
+// clearLastFrameEnd fills the symbols carried over from the last frame with erasures
+func (d *Decoder) clearLastFrameEnd() {
+	for i := range d.LastFrameEnd {
+		d.LastFrameEnd[i] = 128
+	}
+}
+
+// Reset puts the decoder back the way it started: the sync tracking, the symbols carried over from the
+// last frame and all of the stats are cleared. The correlator and Viterbi decoder start afresh on every
+// frame, so there's nothing to clear there. It's safe to call while Start is running; any frame being
+// read at the time is thrown away
+func (d *Decoder) Reset() {
+	d.workMutex.Lock()
+	defer d.workMutex.Unlock()
+
+	d.resets++
+	d.pendingSymbols = nil
+	d.clearLastFrameEnd()
+	d.lastFrameOk = false
+	d.recheckCounter = 0
+	d.currentFrameCorrupt = false
+
+	d.StatsMutex.Lock()
+	d.FrameLock = false
+	d.SigQuality = 0
+	d.AverageRsCorrections = 0
+	d.AvgVitCorrections = 0
+	d.RSCorrectedBytes = 0
+	d.RSTotalProcessedBytes = 0
+	d.TotalFramesProcessed = 0
+	d.Channels = make(map[int]*ChannelStats)
+	d.PhaseInverted = false
+	d.PhaseFlips = 0
+	d.StatsMutex.Unlock()
+
+	for _, output := range d.FramesOutputs {
+		output.Stats.Reset()
+		output.lastWarning = time.Time{}
+		output.warnedDrops = 0
+	}
+	d.publishStats()
+}
+
 func (d *Decoder) checkIfFrameLocked() {
 	// Use the correlator to see where the sync words are in the frame, such that we know where the packet starts
 	// If we're not frame locked, or we've gotten a lot of good packets and should make sure were on the right
 	// track and not out of sync, then try to recorrelate, otherwise, don't try and recorrelate the whole frame
 	if !d.lastFrameOk || d.recheckCounter >= d.MaxRecheckThreshold {
-		d.Correlator.Correlate(&d.EncodedBytes[0], uint(d.EncodedFrameSize))
+		d.Correlator.Correlate(d.EncodedBytes[:d.EncodedFrameSize])
 		d.recheckCounter = 0
 		d.lastFrameOk = false
 	} else {
 		//If we're already locked
-		d.Correlator.Correlate(&d.EncodedBytes[0], uint(d.EncodedFrameSize/64))
+		d.Correlator.Correlate(d.EncodedBytes[:d.EncodedFrameSize/64])
 		if d.Correlator.GetHighestCorrelationPosition() != 0 {
 			//Lost lock, so lets recorrelate the whole frame
-			d.Correlator.Correlate(&d.EncodedBytes[0], uint(d.EncodedFrameSize))
+			d.Correlator.Correlate(d.EncodedBytes[:d.EncodedFrameSize])
 			d.recheckCounter = 0
 		}
 	}
 	d.recheckCounter++
 }
 
-func (d *Decoder) correlate() error {
+func (d *Decoder) correlate(ctx context.Context) error {
 	// Check to make sure we actually got enough data that contains a packet/frame
 	if correlation := d.Correlator.GetHighestCorrelation(); correlation < d.MinCorrelationBits {
 		log.Debugf("Correlation did not meet criteria: have: %d, want: %d", correlation, d.MinCorrelationBits)
@@ -177,21 +314,40 @@ func (d *Decoder) correlate() error {
 
 		// Backfill bytes from the input channel to make a full frame
 		offset := uint(d.EncodedFrameSize) - pos
-		for i := offset; i < uint(d.EncodedFrameSize); i++ {
-			d.EncodedBytes[i] = <-d.SymbolsInput
-
+		if err := d.readSymbols(ctx, d.EncodedBytes[offset:d.EncodedFrameSize]); err != nil {
+			return err
 		}
 	}
+
+	d.fixPhase()
 	return nil
 }
 
+// fixPhase checks which sync word the correlator matched, and if the carrier is locked 180 degrees out,
+// inverts the soft symbols so the viterbi decoder sees the frame the right way up
+func (d *Decoder) fixPhase() {
+	inverted := d.Correlator.GetCorrelationWordNumber() == 1
+
+	d.StatsMutex.Lock()
+	if inverted != d.PhaseInverted {
+		d.PhaseInverted = inverted
+		d.PhaseFlips++
+		log.Infof("[Data-Link] Phase changed, symbols inverted: %v", inverted)
+	}
+	d.StatsMutex.Unlock()
+
+	if inverted {
+		InvertSymbols(d.EncodedBytes[:d.EncodedFrameSize])
+	}
+}
+
 func (d *Decoder) convolutionalDecode() {
 	// Prepend the remaining bits from last chunk to vit data so that the viterbi problem space is larger
 	// And therefore more likely to get a lock/decode
 	copy(d.ViterbiBytes[:d.LastFrameSizeBits], d.LastFrameEnd[:d.LastFrameSizeBits])
 	copy(d.ViterbiBytes[d.LastFrameSizeBits:], d.EncodedBytes[:d.EncodedFrameSize])
 
-	d.Viterbi.Decode(&d.ViterbiBytes[0], &d.DecodedBytes[0])
+	d.Viterbi.Decode(d.ViterbiBytes, d.DecodedBytes)
 
 }
 
@@ -228,10 +384,10 @@ func (d *Decoder) errorCorrectPacket() {
 	totalBytesFixed := int32(0)
 
 	for i := 0; i < int(d.RsBlocks); i++ {
-		d.ReedSolomon.Deinterleave(&d.DecodedBytes[0], &d.RSWorkBuffer[0], byte(i), d.RsBlocks)
-		derrors[i] = int32(int8(d.ReedSolomon.Decode_ccsds(&d.RSWorkBuffer[0])))
+		d.ReedSolomon.Deinterleave(d.DecodedBytes, d.RSWorkBuffer, byte(i), d.RsBlocks)
+		derrors[i] = int32(d.ReedSolomon.DecodeCCSDS(d.RSWorkBuffer))
 
-		d.ReedSolomon.Interleave(&d.RSWorkBuffer[0], &d.RSCorrectedData[0], byte(i), d.RsBlocks)
+		d.ReedSolomon.Interleave(d.RSWorkBuffer, d.RSCorrectedData, byte(i), d.RsBlocks)
 
 		if derrors[i] > -1 {
 			totalBytesFixed += derrors[i]
@@ -252,83 +408,143 @@ func (d *Decoder) errorCorrectPacket() {
 
 }
 
-func (d *Decoder) Start() {
+// emitFrame hands a copy of the corrected VCDU (the frame without its sync word and RS parity) to
+// everything consuming frames, e.g. the transport layer and the frame publisher. If a consumer is behind,
+// this waits for it, and so does Reset, unless it's lossy
+func (d *Decoder) emitFrame(ctx context.Context) {
+	if len(d.FramesOutputs) == 0 {
+		return
+	}
+
+	frame := make([]byte, d.VCDUSize)
+	copy(frame, d.RSCorrectedData[:d.VCDUSize])
+	for _, output := range d.FramesOutputs {
+		if !output.Lossy {
+			queue.Send(ctx, *output.Frames, frame, &output.Stats)
+		} else if !queue.TrySend(*output.Frames, frame, &output.Stats) {
+			output.warnDropped(time.Now())
+		}
+	}
+}
+
+// Start decodes frames until ctx is cancelled or the demodulator closes SymbolsInput, then closes the
+// frame outputs. A partially received frame is discarded
+func (d *Decoder) Start(ctx context.Context) {
+	defer d.Close()
+	d.workMutex.Lock()
+	defer d.workMutex.Unlock()
 	for {
+		// Let everyone see how the last frame went before waiting on the next one
+		d.publishStats()
+
 		//This is the meat and potatoes here. We should get our BER, SNR, and Sync status here
-		if len(d.SymbolsInput) >= d.EncodedFrameSize {
-			//Grab a frame's worth of symbols
-			for i := 0; i < d.EncodedFrameSize; i++ {
-				d.EncodedBytes[i] = <-d.SymbolsInput
-			}
+		//Grab a frame's worth of symbols
+		if err := d.readSymbols(ctx, d.EncodedBytes[:d.EncodedFrameSize]); err == errStopped {
+			log.Debug("[Data-Link] Stopping")
+			return
+		} else if err != nil {
+			continue
+		}
 
-			//Do we have frame sync?
-			d.checkIfFrameLocked()
+		//Do we have frame sync?
+		d.checkIfFrameLocked()
 
-			//Find beginning of frame
-			if err := d.correlate(); err != nil {
-				// If the correlation errored, we don't have a good frame, so skip to next iteration
-				continue
-			}
+		//Find beginning of frame
+		if err := d.correlate(ctx); err == errStopped {
+			log.Debug("[Data-Link] Stopping")
+			return
+		} else if err != nil {
+			// If the correlation errored, we don't have a good frame, so skip to next iteration
+			continue
+		}
 
-			//Decode convolutional encoding
-			d.convolutionalDecode()
+		//Decode convolutional encoding
+		d.convolutionalDecode()
 
-			//Now lets do the differential decode
-			SatHelper.DifferentialEncodingNrzmDecode(&d.DecodedBytes[0], d.FrameSize+d.LastFrameSizeBytes)
+		//Now lets do the differential decode
+		nrzmDecode(d.DecodedBytes[:d.FrameSize+d.LastFrameSizeBytes])
 
-			BER := d.calculateBitErrorRate()
+		BER := d.calculateBitErrorRate()
 
-			// Calculate our 'signal quality' percentage based upon the bit error rate
-			d.StatsMutex.Lock()
-			d.SigQuality = 100 * ((float32(d.MaxVitErrors) - float32(BER)) / float32(d.MaxVitErrors))
-			if d.SigQuality > 100 {
-				d.SigQuality = 100
-			} else if d.SigQuality < 0 {
-				d.SigQuality = 0
-			}
-			d.StatsMutex.Unlock()
+		// Calculate our 'signal quality' percentage based upon the bit error rate
+		d.StatsMutex.Lock()
+		d.SigQuality = 100 * ((float32(d.MaxVitErrors) - float32(BER)) / float32(d.MaxVitErrors))
+		if d.SigQuality > 100 {
+			d.SigQuality = 100
+		} else if d.SigQuality < 0 {
+			d.SigQuality = 0
+		}
+		d.StatsMutex.Unlock()
 
-			d.cleanFrame()
+		d.cleanFrame()
 
-			// Derandomize packet: Unsure what exactly this does tbh
-			SatHelper.DeRandomizerDeRandomize(&d.DecodedBytes[0], d.FrameSize-d.SyncWordSize)
+		// Derandomize packet: Unsure what exactly this does tbh
+		derandomize(d.DecodedBytes[:d.FrameSize-d.SyncWordSize])
 
-			d.errorCorrectPacket()
+		d.errorCorrectPacket()
 
+		d.StatsMutex.Lock()
+		d.TotalFramesProcessed++
+		d.StatsMutex.Unlock()
+
+		// Spacecraft ID (TODO: This seems to always be 0 for some reason?)
+		scid := ((d.RSCorrectedData[0] & 0x3F) << 2) | (d.RSCorrectedData[1]&0xC0)>>6
+
+		// Virtual Channel ID
+		vcid := d.RSCorrectedData[1] & 0x3F
+
+		// 24 bit VCDU counter
+		counter := uint(d.RSCorrectedData[2])<<16 | uint(d.RSCorrectedData[3])<<8 | uint(d.RSCorrectedData[4])
+
+		if !d.currentFrameCorrupt {
 			d.StatsMutex.Lock()
-			d.TotalFramesProcessed++
+			d.FrameLock = true
 			d.StatsMutex.Unlock()
 
-			// Spacecraft ID (TODO: This seems to always be 0 for some reason?)
-			scid := ((d.RSCorrectedData[0] & 0x3F) << 2) | (d.RSCorrectedData[1]&0xC0)>>6
-
-			// Virtual Channel ID
-			vcid := d.RSCorrectedData[1] & 0x3F
-
-			counter := uint(d.RSCorrectedData[2])
-			counter = SatHelper.ToolsSwapEndianess(counter)
-			counter &= 0xFFFFFF00
-			counter >>= 8
-
-			if !d.currentFrameCorrupt {
-				d.StatsMutex.Lock()
-				d.FrameLock = true
-				d.StatsMutex.Unlock()
-
-				log.Infof("[Data-Link] Got frame: vcid: %d (%s) scid: %d object number: %d", int(vcid), VCIDs[int(vcid)], scid, counter)
-				d.StatsMutex.Lock()
-				d.RxPacketsPerChannel[int(vcid)]++
-				d.StatsMutex.Unlock()
-			} else {
-				d.StatsMutex.Lock()
-				d.DroppedPacketsPerChannel[int(vcid)]++
-				d.FrameLock = false
-				d.StatsMutex.Unlock()
-			}
+			log.Infof("[Data-Link] Got frame: vcid: %d (%s) scid: %d object number: %d", int(vcid), VCIDName(int(vcid)), scid, counter)
+			d.StatsMutex.Lock()
+			d.channel(int(vcid)).addFrame(time.Now(), d.VCDUSize)
+			d.StatsMutex.Unlock()
 
+			d.emitFrame(ctx)
 		} else {
-			// Not enough symbols available, so lets sleep on it
-			time.Sleep(5 * time.Microsecond)
+			d.StatsMutex.Lock()
+			d.channel(int(vcid)).Dropped++
+			d.FrameLock = false
+			d.StatsMutex.Unlock()
 		}
 	}
 }
+
+// readSymbols fills buf from the batches of symbols the demodulator sends, waiting for more as needed. It
+// returns errStopped if ctx is cancelled or the demodulator stops first, and errReset if the decoder was
+// reset while it was waiting
+func (d *Decoder) readSymbols(ctx context.Context, buf []byte) error {
+	resets := d.resets
+	for n := 0; n < len(buf); {
+		if len(d.pendingSymbols) == 0 {
+			// Let Reset in while we wait
+			d.workMutex.Unlock()
+			var symbols []byte
+			ok := false
+			select {
+			case <-ctx.Done():
+			case symbols, ok = <-d.SymbolsInput:
+			}
+			d.workMutex.Lock()
+
+			if !ok {
+				return errStopped
+			}
+			d.pendingSymbols = symbols
+			if d.resets != resets {
+				return errReset
+			}
+			continue
+		}
+		copied := copy(buf[n:], d.pendingSymbols)
+		d.pendingSymbols = d.pendingSymbols[copied:]
+		n += copied
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package datalink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrwynneiii/goestuner/queue"
+)
+
+func TestEmitFrameBackpressure(t *testing.T) {
+	transport := make(chan []byte, 1)
+	framedump := make(chan []byte, 1)
+	d := &Decoder{
+		VCDUSize:        892,
+		RSCorrectedData: make([]byte, 1020),
+		FramesOutputs: []*FrameOutput{
+			{Name: "transport", Frames: &transport},
+			{Name: "framedump", Frames: &framedump, Lossy: true},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d.emitFrame(ctx)
+
+	// Both queues are full now. The transport layer holds the decoder up, and the publisher loses the frame
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.emitFrame(ctx)
+	}()
+	select {
+	case <-done:
+		t.Fatal("emitFrame() didn't wait for the transport layer")
+	case <-time.After(20 * time.Millisecond):
+	}
+	<-transport
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitFrame() didn't carry on once the transport layer caught up")
+	}
+
+	transportStats, framedumpStats := &d.FramesOutputs[0].Stats, &d.FramesOutputs[1].Stats
+	if transportStats.Sent() != 2 || transportStats.Dropped() != 0 || transportStats.Stalls() != 1 {
+		t.Errorf("transport: %d sent, %d dropped, %d stalls, want 2, 0, 1", transportStats.Sent(), transportStats.Dropped(), transportStats.Stalls())
+	}
+	if framedumpStats.Sent() != 1 || framedumpStats.Dropped() != 1 {
+		t.Errorf("framedump: %d sent, %d dropped, want 1, 1", framedumpStats.Sent(), framedumpStats.Dropped())
+	}
+
+	// Stopping the decoder doesn't wait on the transport layer
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	d.emitFrame(ctx)
+}
+
+func TestFrameDropWarnings(t *testing.T) {
+	// Nothing reads from it, so every frame is dropped
+	framedump := make(chan []byte)
+	output := &FrameOutput{Name: "framedump", Frames: &framedump, Lossy: true}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// A warning for the first drop, then nothing until frameDropWarningInterval has gone by, when the next
+	// one counts up everything dropped since
+	tests := []struct {
+		at          time.Duration
+		wantWarning bool
+		wantWarned  int64
+	}{
+		{0, true, 1},
+		{time.Second, false, 1},
+		{frameDropWarningInterval - time.Millisecond, false, 1},
+		{frameDropWarningInterval, true, 4},
+		{frameDropWarningInterval + time.Second, false, 4},
+	}
+	for i, tt := range tests {
+		if queue.TrySend(framedump, nil, &output.Stats) {
+			t.Fatal("sent a frame with nothing to receive it")
+		}
+		output.warnDropped(start.Add(tt.at))
+		warned := output.lastWarning.Equal(start.Add(tt.at))
+		if warned != tt.wantWarning || output.warnedDrops != tt.wantWarned {
+			t.Errorf("drop %d at %v: warned %v, %d drops warned about, want %v, %d", i, tt.at, warned, output.warnedDrops, tt.wantWarning, tt.wantWarned)
+		}
+	}
+}
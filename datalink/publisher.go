@@ -0,0 +1,242 @@
+package datalink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/config"
+	"github.com/knadh/koanf/v2"
+)
+
+// Scalability protocol IDs used by nanomsg/nng's pub/sub sockets. goesrecv publishes its packets on a
+// PUB socket, and goesproc (or anything else using nng) subscribes with a SUB socket
+const (
+	spProtocolPub = 0x20
+	spProtocolSub = 0x21
+)
+
+// How many frames we'll queue up for a subscriber before we start dropping frames for it
+const subscriberQueueSize = 1024
+
+type subscriber struct {
+	conn   net.Conn
+	ipc    bool
+	frames chan []byte
+}
+
+// Publisher writes every VCDU the decoder corrects to a .bin file, and/or serves them to clients on a
+// TCP or Unix socket using the same nanomsg pub/sub framing as goesrecv
+type Publisher struct {
+	FrameInput  chan []byte
+	file        *os.File
+	listeners   []net.Listener
+	subscribers map[*subscriber]bool
+	// Every open connection, including the ones still in the handshake
+	conns map[net.Conn]bool
+	// Set by Close, so no more connections or subscribers are taken on
+	closed   bool
+	subMutex sync.Mutex
+	// Counts the goroutines accepting and serving subscribers, so Close can wait for them
+	workers sync.WaitGroup
+}
+
+func NewPublisher(bufsize uint, configFile *koanf.Koanf) *Publisher {
+	conf := config.FrameDumpConf{
+		File:       configFile.String("framedump.file"),
+		TCPAddress: configFile.String("framedump.tcp_address"),
+		UnixSocket: configFile.String("framedump.unix_socket"),
+	}
+	log.Debugf("Found framedump definition: %##v", conf)
+
+	p := Publisher{
+		FrameInput:  make(chan []byte, bufsize),
+		subscribers: make(map[*subscriber]bool),
+		conns:       make(map[net.Conn]bool),
+	}
+
+	if conf.File != "" {
+		f, err := os.OpenFile(conf.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Errorf("Could not open frame dump file %s: %v", conf.File, err)
+		} else {
+			log.Infof("Writing frames to %s", conf.File)
+			p.file = f
+		}
+	}
+
+	if conf.TCPAddress != "" {
+		p.listen("tcp", conf.TCPAddress, false)
+	}
+
+	if conf.UnixSocket != "" {
+		// Clean up the socket left behind by a previous run
+		os.Remove(conf.UnixSocket)
+		p.listen("unix", conf.UnixSocket, true)
+	}
+
+	return &p
+}
+
+// Enabled reports whether the config asked for frames to go anywhere
+func (p *Publisher) Enabled() bool {
+	return p.file != nil || len(p.listeners) > 0
+}
+
+func (p *Publisher) listen(network string, address string, ipc bool) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		log.Errorf("Could not listen for frame subscribers on %s %s: %v", network, address, err)
+		return
+	}
+	log.Infof("Publishing frames on %s %s", network, address)
+	p.listeners = append(p.listeners, l)
+
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			p.workers.Add(1)
+			go func() {
+				defer p.workers.Done()
+				p.serve(conn, ipc)
+			}()
+		}
+	}()
+}
+
+// serve runs the SP handshake with a new subscriber and then streams frames to it until it goes away
+func (p *Publisher) serve(conn net.Conn, ipc bool) {
+	defer conn.Close()
+	p.subMutex.Lock()
+	if p.closed {
+		p.subMutex.Unlock()
+		return
+	}
+	p.conns[conn] = true
+	p.subMutex.Unlock()
+	defer func() {
+		p.subMutex.Lock()
+		delete(p.conns, conn)
+		p.subMutex.Unlock()
+	}()
+
+	// Both ends send their 8 byte SP header at the same time
+	header := []byte{0x00, 'S', 'P', 0x00, 0x00, spProtocolPub, 0x00, 0x00}
+	if _, err := conn.Write(header); err != nil {
+		return
+	}
+	peer := make([]byte, len(header))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, peer); err != nil {
+		log.Debugf("Frame subscriber %s did not complete handshake: %v", conn.RemoteAddr(), err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if !bytes.Equal(peer[:4], header[:4]) || binary.BigEndian.Uint16(peer[4:6]) != spProtocolSub {
+		log.Warnf("Frame subscriber %s is not a nanomsg SUB socket", conn.RemoteAddr())
+		return
+	}
+
+	sub := &subscriber{
+		conn:   conn,
+		ipc:    ipc,
+		frames: make(chan []byte, subscriberQueueSize),
+	}
+	p.subMutex.Lock()
+	if p.closed {
+		// Close got to the connection during the handshake
+		p.subMutex.Unlock()
+		return
+	}
+	p.subscribers[sub] = true
+	p.subMutex.Unlock()
+	log.Infof("Frame subscriber connected: %s", conn.RemoteAddr())
+
+	// Subscribers never send us anything after the handshake, so a read only returns when they leave
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		io.Copy(io.Discard, conn)
+		conn.Close()
+	}()
+
+	for frame := range sub.frames {
+		if err := writeMessage(conn, frame, sub.ipc); err != nil {
+			break
+		}
+	}
+
+	p.subMutex.Lock()
+	delete(p.subscribers, sub)
+	p.subMutex.Unlock()
+	log.Infof("Frame subscriber disconnected: %s", conn.RemoteAddr())
+}
+
+// writeMessage frames a message the way nng's transports expect: a 64 bit big endian length over
+// TCP, with an extra message type byte in front of it over IPC
+func writeMessage(w io.Writer, msg []byte, ipc bool) error {
+	var hdr []byte
+	if ipc {
+		hdr = append(hdr, 0x01)
+	}
+	hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(msg)))
+	if _, err := w.Write(append(hdr, msg...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *Publisher) Start() {
+	for frame := range p.FrameInput {
+		if p.file != nil {
+			if _, err := p.file.Write(frame); err != nil {
+				log.Errorf("Could not write frame to %s: %v", p.file.Name(), err)
+			}
+		}
+
+		p.subMutex.Lock()
+		for sub := range p.subscribers {
+			select {
+			case sub.frames <- frame:
+			default:
+				log.Debugf("Frame subscriber %s is falling behind, dropping frame", sub.conn.RemoteAddr())
+			}
+		}
+		p.subMutex.Unlock()
+	}
+}
+
+// Close disconnects every subscriber, stops listening for more, and waits for everything serving them to
+// finish. Start must have returned first
+func (p *Publisher) Close() {
+	for _, l := range p.listeners {
+		l.Close()
+	}
+	p.subMutex.Lock()
+	p.closed = true
+	for sub := range p.subscribers {
+		close(sub.frames)
+		delete(p.subscribers, sub)
+	}
+	// Closing the connections too stops any handshake, and any write stuck on a subscriber that stopped
+	// reading
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.subMutex.Unlock()
+	p.workers.Wait()
+
+	if p.file != nil {
+		p.file.Close()
+	}
+}
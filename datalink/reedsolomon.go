@@ -0,0 +1,223 @@
+package datalink
+
+// Parameters of the CCSDS (255,223) Reed-Solomon code
+const (
+	rsBlockSize = 255
+	rsDataSize  = 223
+	rsNumRoots  = rsBlockSize - rsDataSize
+	// Field generator polynomial x^8 + x^7 + x^2 + x + 1
+	rsFieldPoly = 0x187
+	// The code's roots are alpha^(rsPrimitive * (rsFirstRoot + i))
+	rsFirstRoot = 112
+	rsPrimitive = 11
+)
+
+// GF(256) exponent and log tables
+var (
+	gfExp [2 * rsBlockSize]byte
+	gfLog [rsBlockSize + 1]int
+)
+
+// CCSDS sends codewords in Berlekamp's dual basis rather than the conventional one the decoder works in
+var (
+	toDualBasis   [256]byte
+	fromDualBasis [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < rsBlockSize; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+rsBlockSize] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= rsFieldPoly
+		}
+	}
+
+	// Rows of the conventional to dual basis transformation matrix, from CCSDS 101.0-B
+	tal := [8]byte{0x8d, 0xef, 0xec, 0x86, 0xfa, 0x99, 0xaf, 0x7b}
+	for i := 0; i < 256; i++ {
+		var dual byte
+		for k := 0; k < 8; k++ {
+			if i&(1<<k) != 0 {
+				dual ^= tal[7-k]
+			}
+		}
+		toDualBasis[i] = dual
+		fromDualBasis[dual] = byte(i)
+	}
+}
+
+func gfMul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]-gfLog[b]+rsBlockSize)%rsBlockSize]
+}
+
+// gfPow returns alpha^n
+func gfPow(n int) byte {
+	n %= rsBlockSize
+	if n < 0 {
+		n += rsBlockSize
+	}
+	return gfExp[n]
+}
+
+// gfEval evaluates a polynomial, lowest order coefficient first, at x
+func gfEval(poly []byte, x byte) byte {
+	var y byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ poly[i]
+	}
+	return y
+}
+
+// ReedSolomon is a pure Go decoder for the CCSDS (255,223) Reed-Solomon code
+type ReedSolomon struct {
+	work [rsBlockSize]byte
+}
+
+func NewReedSolomon() *ReedSolomon {
+	return &ReedSolomon{}
+}
+
+func (r *ReedSolomon) Deinterleave(data []byte, output []byte, pos byte, n byte) {
+	for i := 0; i < rsBlockSize; i++ {
+		output[i] = data[i*int(n)+int(pos)]
+	}
+}
+
+func (r *ReedSolomon) Interleave(data []byte, output []byte, pos byte, n byte) {
+	for i := 0; i < rsBlockSize; i++ {
+		output[i*int(n)+int(pos)] = data[i]
+	}
+}
+
+func (r *ReedSolomon) DecodeCCSDS(data []byte) int {
+	for i := 0; i < rsBlockSize; i++ {
+		r.work[i] = fromDualBasis[data[i]]
+	}
+	corrected := r.decode(r.work[:])
+	if corrected < 0 {
+		return corrected
+	}
+	for i := 0; i < rsBlockSize; i++ {
+		data[i] = toDualBasis[r.work[i]]
+	}
+	return corrected
+}
+
+// decode corrects a codeword in the conventional basis in place, with the first byte being the highest
+// order coefficient. It returns how many bytes it fixed, parity included as libsathelper counts them, or -1
+// if there were too many errors
+func (r *ReedSolomon) decode(data []byte) int {
+	// Evaluate the received word at each of the code's roots
+	var syndromes [rsNumRoots]byte
+	clean := true
+	for i := range syndromes {
+		root := gfPow(rsPrimitive * (rsFirstRoot + i))
+		var s byte
+		for _, b := range data[:rsBlockSize] {
+			s = gfMul(s, root) ^ b
+		}
+		syndromes[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return 0
+	}
+
+	// Berlekamp-Massey, to find the error locator polynomial
+	locator := make([]byte, 1, rsNumRoots+1)
+	locator[0] = 1
+	prev := []byte{1}
+	length, shift := 0, 1
+	prevDiscrepancy := byte(1)
+	for n := 0; n < rsNumRoots; n++ {
+		d := syndromes[n]
+		for i := 1; i <= length && i < len(locator); i++ {
+			d ^= gfMul(locator[i], syndromes[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+
+		scale := gfDiv(d, prevDiscrepancy)
+		updated := make([]byte, max(len(locator), len(prev)+shift))
+		copy(updated, locator)
+		for i, c := range prev {
+			updated[i+shift] ^= gfMul(scale, c)
+		}
+		if 2*length <= n {
+			prev = locator
+			length = n + 1 - length
+			prevDiscrepancy = d
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = updated
+	}
+	for len(locator) > 1 && locator[len(locator)-1] == 0 {
+		locator = locator[:len(locator)-1]
+	}
+	if len(locator)-1 != length || length > rsNumRoots/2 {
+		return -1
+	}
+
+	// Error evaluator: syndromes * locator mod x^rsNumRoots
+	evaluator := make([]byte, rsNumRoots)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+
+	// Formal derivative of the locator, which in GF(2^8) keeps only the odd powers
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+
+	// Chien search for the error positions, and Forney's algorithm for their values
+	type correction struct {
+		pos   int
+		value byte
+	}
+	var corrections []correction
+	for pos := 0; pos < rsBlockSize; pos++ {
+		// Byte pos is the coefficient of x^(254 - pos), so its locator is beta^(254 - pos)
+		power := rsPrimitive * (rsBlockSize - 1 - pos)
+		xInv := gfPow(-power)
+		if gfEval(locator, xInv) != 0 {
+			continue
+		}
+		denominator := gfEval(derivative, xInv)
+		if denominator == 0 {
+			return -1
+		}
+		value := gfMul(gfPow(power*(1-rsFirstRoot)), gfDiv(gfEval(evaluator, xInv), denominator))
+		corrections = append(corrections, correction{pos, value})
+	}
+	if len(corrections) != length {
+		return -1
+	}
+
+	for _, c := range corrections {
+		data[c.pos] ^= c.value
+	}
+	return len(corrections)
+}
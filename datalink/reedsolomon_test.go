@@ -0,0 +1,125 @@
+package datalink
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// rsEncode builds a dual basis CCSDS codeword from 223 data bytes, by dividing the data by the code's
+// generator polynomial in the conventional basis and appending the remainder as parity
+func rsEncode(data []byte) []byte {
+	// The generator's roots are the ones decode evaluates the syndromes at. Highest order coefficient
+	// first, with the x^32 term left implicit
+	generator := []byte{1}
+	for i := 0; i < rsNumRoots; i++ {
+		root := gfPow(rsPrimitive * (rsFirstRoot + i))
+		next := make([]byte, len(generator)+1)
+		for j, c := range generator {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, root)
+		}
+		generator = next
+	}
+
+	codeword := make([]byte, rsBlockSize)
+	for i, b := range data[:rsDataSize] {
+		codeword[i] = fromDualBasis[b]
+	}
+	parity := make([]byte, rsNumRoots)
+	for _, b := range codeword[:rsDataSize] {
+		feedback := b ^ parity[0]
+		copy(parity, parity[1:])
+		parity[rsNumRoots-1] = 0
+		for j := range parity {
+			parity[j] ^= gfMul(feedback, generator[j+1])
+		}
+	}
+	copy(codeword[rsDataSize:], parity)
+
+	for i := range codeword {
+		codeword[i] = toDualBasis[codeword[i]]
+	}
+	return codeword
+}
+
+func TestDualBasis(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		if got := fromDualBasis[toDualBasis[i]]; got != byte(i) {
+			t.Fatalf("%#02x went to the dual basis and back as %#02x", i, got)
+		}
+	}
+}
+
+func TestReedSolomonRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		// Positions of the bytes to corrupt; parity starts at 223
+		positions []int
+		// What DecodeCCSDS should return
+		want int
+	}{
+		{"clean", nil, 0},
+		{"one data byte", []int{17}, 1},
+		{"first and last bytes", []int{0, 254}, 2},
+		{"parity only", []int{223, 230, 254}, 3},
+		{"16 data bytes", []int{1, 14, 27, 40, 53, 66, 79, 92, 105, 118, 131, 144, 157, 170, 183, 196}, 16},
+		{"16 bytes across data and parity", []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 200, 225, 235, 245, 254}, 16},
+		{"17 bytes", []int{1, 14, 27, 40, 53, 66, 79, 92, 105, 118, 131, 144, 157, 170, 183, 196, 240}, -1},
+		{"32 bytes", []int{
+			0, 7, 14, 21, 28, 35, 42, 49, 56, 63, 70, 77, 84, 91, 98, 105,
+			112, 119, 126, 133, 140, 147, 154, 161, 168, 175, 182, 189, 196, 203, 210, 217,
+		}, -1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(34, uint64(i)))
+			data := make([]byte, rsDataSize)
+			for j := range data {
+				data[j] = byte(rng.UintN(256))
+			}
+			codeword := rsEncode(data)
+			received := bytes.Clone(codeword)
+			for _, pos := range tt.positions {
+				received[pos] ^= byte(1 + rng.UintN(255))
+			}
+			corrupted := bytes.Clone(received)
+
+			got := NewReedSolomon().DecodeCCSDS(received)
+			if got != tt.want {
+				t.Fatalf("DecodeCCSDS() = %d, want %d", got, tt.want)
+			}
+			if tt.want < 0 {
+				if !bytes.Equal(received, corrupted) {
+					t.Errorf("an uncorrectable codeword was changed")
+				}
+			} else if !bytes.Equal(received, codeword) {
+				t.Errorf("corrected codeword doesn't match what was sent")
+			}
+		})
+	}
+}
+
+func TestReedSolomonInterleave(t *testing.T) {
+	const depth = 4
+	rs := NewReedSolomon()
+	frame := make([]byte, rsBlockSize*depth)
+	for i := range frame {
+		frame[i] = byte(i * 7)
+	}
+
+	codeword := make([]byte, rsBlockSize)
+	output := make([]byte, len(frame))
+	for pos := byte(0); pos < depth; pos++ {
+		rs.Deinterleave(frame, codeword, pos, depth)
+		for i, b := range codeword {
+			if want := frame[i*depth+int(pos)]; b != want {
+				t.Fatalf("codeword %d byte %d = %#02x, want %#02x", pos, i, b, want)
+			}
+		}
+		rs.Interleave(codeword, output, pos, depth)
+	}
+	if !bytes.Equal(output, frame) {
+		t.Errorf("interleaving the codewords back didn't rebuild the frame")
+	}
+}
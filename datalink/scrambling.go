@@ -0,0 +1,43 @@
+package datalink
+
+// CCSDS pseudo-random sequence frames are XORed with to guarantee enough bit transitions. It comes
+// from h(x) = x^8 + x^7 + x^5 + x^3 + 1 seeded with all ones, and repeats every 255 bytes
+var pseudoRandomSequence [255]byte
+
+func init() {
+	state := byte(0xFF)
+	for i := range pseudoRandomSequence {
+		var b byte
+		for bit := 0; bit < 8; bit++ {
+			b = b<<1 | state>>7
+			feedback := (state>>7 ^ state>>4 ^ state>>2 ^ state) & 1
+			state = state<<1 | feedback
+		}
+		pseudoRandomSequence[i] = b
+	}
+}
+
+// NRZMDecode undoes the differential (NRZ-M) encoding of a frame in place: a 1 bit means the line
+// changed state, a 0 means it didn't
+func NRZMDecode(data []byte) {
+	var lastBit byte
+	for i := range data {
+		mask := (data[i] >> 1) | (lastBit << 7)
+		lastBit = data[i] & 1
+		data[i] ^= mask
+	}
+}
+
+// DeRandomize removes the CCSDS pseudo-randomization from a frame in place
+func DeRandomize(data []byte) {
+	for i := range data {
+		data[i] ^= pseudoRandomSequence[i%len(pseudoRandomSequence)]
+	}
+}
+
+// InvertSymbols flips soft symbols in place, which undoes a 180 degree phase error on BPSK
+func InvertSymbols(data []byte) {
+	for i := range data {
+		data[i] ^= 0xFF
+	}
+}
@@ -0,0 +1,54 @@
+package datalink
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The first bytes of the CCSDS pseudo-random sequence, from CCSDS 131.0-B
+var pseudoRandomStart = []byte{
+	0xFF, 0x48, 0x0E, 0xC0, 0x9A, 0x0D, 0x70, 0xBC, 0x8E, 0x2C, 0x93, 0xAD, 0xA7, 0xB7, 0x46, 0xCE,
+	0x5A, 0x97, 0x7D, 0xCC, 0x32, 0xA2, 0xBF, 0x3E, 0x0A, 0x10, 0xF1, 0x88, 0x94, 0xCD, 0xEA, 0xB1,
+}
+
+func TestPseudoRandomSequence(t *testing.T) {
+	if !bytes.Equal(pseudoRandomSequence[:len(pseudoRandomStart)], pseudoRandomStart) {
+		t.Errorf("sequence starts % X, want % X", pseudoRandomSequence[:len(pseudoRandomStart)], pseudoRandomStart)
+	}
+}
+
+func TestDeRandomize(t *testing.T) {
+	// The sequence repeats every 255 bytes, so a frame longer than that wraps around
+	data := make([]byte, 300)
+	DeRandomize(data)
+	if !bytes.Equal(data[:255], pseudoRandomSequence[:]) || !bytes.Equal(data[255:], pseudoRandomSequence[:45]) {
+		t.Errorf("derandomizing zeros didn't give the sequence")
+	}
+	DeRandomize(data)
+	if !bytes.Equal(data, make([]byte, 300)) {
+		t.Errorf("derandomizing twice didn't give back the frame: % X", data)
+	}
+}
+
+func TestNRZMDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"no transitions", []byte{0x00, 0x00}, []byte{0x00, 0x00}},
+		{"steady high", []byte{0xFF, 0xFF}, []byte{0x80, 0x00}},
+		{"alternating", []byte{0xAA, 0x55}, []byte{0xFF, 0x7F}},
+		{"carries across bytes", []byte{0x01, 0x80}, []byte{0x01, 0x40}},
+		{"carries a high line across bytes", []byte{0x01, 0x00}, []byte{0x01, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Clone(tt.in)
+			NRZMDecode(data)
+			if !bytes.Equal(data, tt.want) {
+				t.Errorf("NRZMDecode(% X) = % X, want % X", tt.in, data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package datalink
+
+import "time"
+
+// DecoderStats is an immutable snapshot of the decoder's stats. The decoder swaps in a new one after every
+// frame, so anything reading it (the TUI, loggers, etc.) always sees a consistent set of values without
+// taking a lock. Don't modify it; it's shared by every reader
+type DecoderStats struct {
+	Time                 time.Time
+	FrameLock            bool
+	TotalFrames          int
+	SigQuality           float32
+	ViterbiPercentBER    float32
+	AverageRsCorrections float64
+	PhaseInverted        bool
+	PhaseFlips           int
+	Channels             map[int]ChannelStats
+}
+
+// TotalDropped is the number of frames dropped across every channel
+func (s *DecoderStats) TotalDropped() int {
+	var dropped int
+	for _, c := range s.Channels {
+		dropped += c.Dropped
+	}
+	return dropped
+}
+
+// Stats returns the latest snapshot of the decoder's stats. It's safe to call from any goroutine
+func (d *Decoder) Stats() *DecoderStats {
+	if stats := d.stats.Load(); stats != nil {
+		return stats
+	}
+	return &DecoderStats{Channels: map[int]ChannelStats{}}
+}
+
+// publishStats swaps in a new snapshot of the decoder's stats
+func (d *Decoder) publishStats() {
+	d.StatsMutex.RLock()
+	stats := DecoderStats{
+		Time:                 time.Now(),
+		FrameLock:            d.FrameLock,
+		TotalFrames:          d.TotalFramesProcessed,
+		SigQuality:           d.SigQuality,
+		ViterbiPercentBER:    d.Viterbi.GetPercentBER(),
+		AverageRsCorrections: d.AverageRsCorrections,
+		PhaseInverted:        d.PhaseInverted,
+		PhaseFlips:           d.PhaseFlips,
+	}
+	d.StatsMutex.RUnlock()
+
+	stats.Channels = d.ChannelSnapshot()
+	d.stats.Store(&stats)
+}
@@ -0,0 +1,145 @@
+package datalink
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Generator polynomials for the CCSDS K=7 code, in the same bit order libcorrect (and so libsathelper)
+// uses: the newest bit in the shift register is the LSB
+const (
+	viterbiPolyA = 0x4F
+	viterbiPolyB = 0x6D
+)
+
+const (
+	viterbiOrder  = 7
+	viterbiStates = 1 << (viterbiOrder - 1)
+)
+
+// Viterbi27 is a pure Go soft decision Viterbi decoder for the rate 1/2, K=7 convolutional code
+type Viterbi27 struct {
+	frameBits int
+	ber       int
+	// Encoder output bits for every value of the shift register, first polynomial in the high bit
+	outputs   [1 << viterbiOrder]byte
+	metrics   [viterbiStates]uint32
+	next      [viterbiStates]uint32
+	decisions []uint64
+	check     []byte
+}
+
+func NewViterbi27(frameBits int) *Viterbi27 {
+	v := Viterbi27{
+		frameBits: frameBits,
+		decisions: make([]uint64, frameBits),
+		check:     make([]byte, frameBits*2),
+	}
+	for reg := range v.outputs {
+		a := bits.OnesCount(uint(reg&viterbiPolyA)) & 1
+		b := bits.OnesCount(uint(reg&viterbiPolyB)) & 1
+		v.outputs[reg] = byte(a<<1 | b)
+	}
+	return &v
+}
+
+// softDistance is how far a pair of soft symbols is from the two encoder output bits
+func softDistance(out byte, a byte, b byte) uint32 {
+	var d uint32
+	if out&2 != 0 {
+		d += uint32(255 - a)
+	} else {
+		d += uint32(a)
+	}
+	if out&1 != 0 {
+		d += uint32(255 - b)
+	} else {
+		d += uint32(b)
+	}
+	return d
+}
+
+func (v *Viterbi27) Decode(input []byte, output []byte) {
+	// The encoder starts out flushed, so every other state starts out unreachable
+	for s := range v.metrics {
+		v.metrics[s] = math.MaxUint32 / 2
+	}
+	v.metrics[0] = 0
+
+	for t := 0; t < v.frameBits; t++ {
+		a, b := input[2*t], input[2*t+1]
+		var decision uint64
+		for s := 0; s < viterbiStates; s++ {
+			// s is the new state; it can be reached from two previous states, which differ only in the
+			// bit that just fell off the end of the register
+			bit := s & 1
+			p0 := s >> 1
+			p1 := p0 | viterbiStates>>1
+			m0 := v.metrics[p0] + softDistance(v.outputs[p0<<1|bit], a, b)
+			m1 := v.metrics[p1] + softDistance(v.outputs[p1<<1|bit], a, b)
+			if m1 < m0 {
+				v.next[s] = m1
+				decision |= 1 << s
+			} else {
+				v.next[s] = m0
+			}
+		}
+		v.decisions[t] = decision
+		v.metrics = v.next
+	}
+
+	// Trace back from whichever state ended up with the best metric
+	state := 0
+	for s := range v.metrics {
+		if v.metrics[s] < v.metrics[state] {
+			state = s
+		}
+	}
+	for i := range output[:v.frameBits/8] {
+		output[i] = 0
+	}
+	for t := v.frameBits - 1; t >= 0; t-- {
+		if state&1 != 0 {
+			output[t/8] |= 0x80 >> (t % 8)
+		}
+		prev := state >> 1
+		if v.decisions[t]&(1<<state) != 0 {
+			prev |= viterbiStates >> 1
+		}
+		state = prev
+	}
+
+	v.ber = v.countErrors(input, output)
+}
+
+// countErrors re-encodes the decoded frame and counts the symbols that differ from what we received
+func (v *Viterbi27) countErrors(input []byte, decoded []byte) int {
+	v.encode(decoded, v.check)
+	errors := 0
+	for i, sym := range v.check {
+		if (input[i] >= 127) != (sym != 0) {
+			errors++
+		}
+	}
+	return errors
+}
+
+// encode convolutionally encodes a frame into hard symbols: 0 or 255 for each output bit
+func (v *Viterbi27) encode(input []byte, output []byte) {
+	reg := 0
+	for t := 0; t < v.frameBits; t++ {
+		bit := int(input[t/8]>>(7-t%8)) & 1
+		reg = (reg<<1 | bit) & (1<<viterbiOrder - 1)
+		out := v.outputs[reg]
+		output[2*t] = 255 * (out >> 1)
+		output[2*t+1] = 255 * (out & 1)
+	}
+}
+
+func (v *Viterbi27) GetBER() int {
+	return v.ber / 2
+}
+
+func (v *Viterbi27) GetPercentBER() float32 {
+	return (100 * float32(v.ber)) / float32(v.frameBits)
+}
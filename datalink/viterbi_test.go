@@ -0,0 +1,86 @@
+package datalink
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// testFrame returns a frame of random bytes. The last byte is left as zeros, which flushes the encoder
+// back to the state the decoder traces back from
+func testFrame(rng *rand.Rand, size int) []byte {
+	frame := make([]byte, size)
+	for i := range frame[:size-1] {
+		frame[i] = byte(rng.UintN(256))
+	}
+	return frame
+}
+
+// corruptSymbols flips each soft symbol with probability ber, and returns how many it flipped
+func corruptSymbols(rng *rand.Rand, symbols []byte, ber float64) int {
+	flipped := 0
+	for i := range symbols {
+		if rng.Float64() < ber {
+			symbols[i] = 255 - symbols[i]
+			flipped++
+		}
+	}
+	return flipped
+}
+
+func TestViterbiRoundTrip(t *testing.T) {
+	const frameSize = 1024
+	tests := []struct {
+		name string
+		ber  float64
+	}{
+		{"clean", 0},
+		{"0.1% errors", 0.001},
+		{"1% errors", 0.01},
+		{"2% errors", 0.02},
+		// About as many hard errors as the code will take over a whole frame
+		{"3% errors", 0.03},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(34, uint64(i)))
+			v := NewViterbi27(frameSize * 8)
+			frame := testFrame(rng, frameSize)
+			symbols := make([]byte, frameSize*8*2)
+			v.encode(frame, symbols)
+			flipped := corruptSymbols(rng, symbols, tt.ber)
+
+			decoded := make([]byte, frameSize)
+			v.Decode(symbols, decoded)
+			if !bytes.Equal(decoded, frame) {
+				t.Fatalf("decoded frame doesn't match after flipping %d symbols", flipped)
+			}
+			// With the frame decoded right, re-encoding it finds exactly the symbols that were flipped
+			if v.ber != flipped {
+				t.Errorf("counted %d symbol errors, want %d", v.ber, flipped)
+			}
+			if want := 100 * float32(flipped) / float32(frameSize*8); v.GetPercentBER() != want {
+				t.Errorf("GetPercentBER() = %f, want %f", v.GetPercentBER(), want)
+			}
+		})
+	}
+}
+
+func TestViterbiSoftErasures(t *testing.T) {
+	// Symbols sitting on the decision boundary carry no information, and shouldn't throw the decode off
+	const frameSize = 256
+	rng := rand.New(rand.NewPCG(34, 100))
+	v := NewViterbi27(frameSize * 8)
+	frame := testFrame(rng, frameSize)
+	symbols := make([]byte, frameSize*8*2)
+	v.encode(frame, symbols)
+	for i := 0; i < len(symbols); i += 7 {
+		symbols[i] = 128
+	}
+
+	decoded := make([]byte, frameSize)
+	v.Decode(symbols, decoded)
+	if !bytes.Equal(decoded, frame) {
+		t.Errorf("decoded frame doesn't match with every 7th symbol erased")
+	}
+}
@@ -0,0 +1,45 @@
+package demod
+
+import (
+	"math"
+)
+
+// AGC scales samples so their magnitude tracks a reference level. It's a port of libsathelper's (and
+// GNU Radio's) simple feedback AGC
+type AGC struct {
+	rate      float32
+	reference float32
+	gain      float32
+	maxGain   float32
+}
+
+func NewAGC(rate float32, reference float32, gain float32, maxGain float32) *AGC {
+	return &AGC{
+		rate:      rate,
+		reference: reference,
+		gain:      gain,
+		maxGain:   maxGain,
+	}
+}
+
+func (a *AGC) GetGain() float32 {
+	return a.gain
+}
+
+func (a *AGC) GetReference() float32 {
+	return a.reference
+}
+
+// Work scales input into output, which must be at least as long
+func (a *AGC) Work(input []complex64, output []complex64) {
+	for i, sample := range input {
+		out := complex(real(sample)*a.gain, imag(sample)*a.gain)
+		magnitude := float32(math.Sqrt(float64(real(out)*real(out) + imag(out)*imag(out))))
+		a.gain += a.rate * (a.reference - magnitude)
+		// A max gain of 0 means there isn't one
+		if a.maxGain > 0 && a.gain > a.maxGain {
+			a.gain = a.maxGain
+		}
+		output[i] = out
+	}
+}
@@ -0,0 +1,170 @@
+package demod
+
+import (
+	"math"
+)
+
+// Number of taps, and fractional steps between samples, of the interpolator the clock recovery uses
+const (
+	interpTaps  = 8
+	interpSteps = 128
+)
+
+// The clock recovery always keeps at least this many samples from the last block
+const minSampleHistory = 3
+
+// interpolator estimates the signal between input[3] and input[4] with a bank of windowed sinc
+// fractional delay filters, one for each 1/interpSteps of a sample
+type interpolator struct {
+	taps [interpSteps + 1][interpTaps]float32
+}
+
+func newInterpolator() *interpolator {
+	var in interpolator
+	for step := range in.taps {
+		mu := float64(step) / interpSteps
+		var sum float64
+		var taps [interpTaps]float64
+		for k := range taps {
+			// Distance from this tap to the point we're interpolating
+			t := float64(k) - (interpTaps/2 - 1) - mu
+			sinc := 1.0
+			if t != 0 {
+				sinc = math.Sin(math.Pi*t) / (math.Pi * t)
+			}
+			// Blackman window spanning the filter
+			w := 2 * math.Pi * t / (interpTaps + 1)
+			window := 0.42 + 0.5*math.Cos(w) + 0.08*math.Cos(2*w)
+			taps[k] = sinc * window
+			sum += taps[k]
+		}
+		// Normalize for unity gain at DC
+		for k := range taps {
+			in.taps[step][k] = float32(taps[k] / sum)
+		}
+	}
+	return &in
+}
+
+func (in *interpolator) interpolate(input []complex64, mu float32) complex64 {
+	step := int(math.Round(float64(mu * interpSteps)))
+	step = max(0, min(step, interpSteps))
+
+	var re, im float32
+	for k, tap := range in.taps[step] {
+		re += real(input[k]) * tap
+		im += imag(input[k]) * tap
+	}
+	return complex(re, im)
+}
+
+// TimingRecovery picks out one sample per symbol from the filtered signal
+type TimingRecovery interface {
+	Work(input []complex64) []complex64
+	GetMu() float32
+	GetOmega() float32
+}
+
+// ClockRecovery is a Mueller and Müller symbol timing recovery loop, ported from libsathelper (which is
+// in turn based on GNU Radio's). It picks one sample per symbol out of a stream at omega samples per
+// symbol, with mu being where between two samples the next symbol is
+type ClockRecovery struct {
+	mu                 float32
+	omega              float32
+	gainOmega          float32
+	gainMu             float32
+	omegaRelativeLimit float32
+	omegaMid           float32
+	omegaLimit         float32
+
+	interp  *interpolator
+	history []complex64
+
+	// Last three interpolated samples, and the symbols we decided they were
+	p2T, p1T, p0T complex64
+	c2T, c1T, c0T complex64
+}
+
+func NewClockRecovery(omega float32, gainOmega float32, mu float32, gainMu float32, omegaRelativeLimit float32) *ClockRecovery {
+	c := ClockRecovery{
+		mu:                 mu,
+		gainOmega:          gainOmega,
+		gainMu:             gainMu,
+		omegaRelativeLimit: omegaRelativeLimit,
+		interp:             newInterpolator(),
+		history:            make([]complex64, minSampleHistory),
+	}
+	c.SetOmega(omega)
+	return &c
+}
+
+func (c *ClockRecovery) GetMu() float32 {
+	return c.mu
+}
+
+func (c *ClockRecovery) GetOmega() float32 {
+	return c.omega
+}
+
+// SetOmega sets the nominal samples per symbol, which the loop is kept within omegaRelativeLimit of
+func (c *ClockRecovery) SetOmega(omega float32) {
+	c.omega = omega
+	c.omegaMid = omega
+	c.omegaLimit = c.omegaRelativeLimit * omega
+}
+
+// slicer makes a hard decision on a sample. It maps to 0 and 1 rather than -1 and 1 like GNU Radio's
+func slicer(sample complex64) complex64 {
+	var re, im float32
+	if real(sample) > 0 {
+		re = 1
+	}
+	if imag(sample) > 0 {
+		im = 1
+	}
+	return complex(re, im)
+}
+
+func clip(x float32, limit float32) float32 {
+	return max(-limit, min(x, limit))
+}
+
+func conj(x complex64) complex64 {
+	return complex(real(x), -imag(x))
+}
+
+// Work returns the symbols recovered from input. Samples it couldn't use yet are kept for the next call
+func (c *ClockRecovery) Work(input []complex64) []complex64 {
+	samples := append(c.history, input...)
+	output := make([]complex64, 0, int(float32(len(samples))/c.omega)+1)
+
+	idx := 0
+	// Leave room for the interpolator, plus a little slack for omega wandering
+	end := len(samples) - interpTaps - 16
+	for idx < end {
+		c.p2T, c.p1T = c.p1T, c.p0T
+		c.p0T = c.interp.interpolate(samples[idx:], c.mu)
+
+		c.c2T, c.c1T = c.c1T, c.c0T
+		c.c0T = slicer(c.p0T)
+
+		x := (c.c0T - c.c2T) * conj(c.p1T)
+		y := (c.p0T - c.p2T) * conj(c.c1T)
+		mmVal := clip(real(y-x), 1.0)
+		output = append(output, c.p0T)
+
+		c.omega += c.gainOmega * mmVal
+		c.omega = c.omegaMid + clip(c.omega-c.omegaMid, c.omegaLimit)
+
+		c.mu += c.omega + c.gainMu*mmVal
+		step := float32(math.Floor(float64(c.mu)))
+		idx = max(0, idx+int(step))
+		c.mu -= step
+	}
+
+	// Hang on to everything we didn't get to
+	keep := max(len(samples)-idx, minSampleHistory)
+	c.history = append(c.history[:0:0], samples[len(samples)-keep:]...)
+
+	return output
+}
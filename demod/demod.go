@@ -1,34 +1,26 @@
 package demod
 
 import (
+	"context"
 	"math"
-	"math/cmplx"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/jrwynneiii/goestuner/config"
+	"github.com/jrwynneiii/goestuner/queue"
 	"github.com/jrwynneiii/goestuner/radio"
 	"github.com/knadh/koanf/v2"
-	SatHelper "github.com/opensatelliteproject/libsathelper"
 	"github.com/racerxdl/segdsp/dsp"
 	"github.com/racerxdl/segdsp/tools"
 	"gonum.org/v1/gonum/dsp/fourier"
 )
 
-type SNRCalc struct {
-	Y1     float64
-	Y2     float64
-	Alpha  float64
-	Beta   float64
-	Signal float64
-	Noise  float64
-}
-
 type Demodulator struct {
 	SampleInput       chan []complex64
 	SampleType        radio.StreamType
-	SymbolsOutput     *chan byte
+	SymbolsOutput     *chan []byte
 	bufferSize        uint
 	circuitSampleRate float32
 	deviceSampleRate  float32
@@ -36,36 +28,37 @@ type Demodulator struct {
 	decimFactor       int
 	sampleChunkSize   int
 	gainOmega         float32
-	AGC               SatHelper.AGC
-	ClockRecovery     SatHelper.ClockRecovery
+	AGC               *AGC
+	ClockRecovery     TimingRecovery
 	RRCFilter         *dsp.FirFilter
 	Decimator         *dsp.FirFilter
+	DCBlocker         *DCBlocker
+	IQCorrector       *IQCorrector
+	Resampler         *RationalResampler
 	CostasLoop        dsp.CostasLoop
+	Translator        *dsp.Rotator
+	Pipeline          []Stage
 	CurrentFFT        []float64
 	DoFFT             bool
 	FFTWorking        bool
-	Stopping          bool
 	FFTMutex          sync.RWMutex
-	SNR               *SNRCalc
+	SNR               SNREstimator
 	CurrentSNR        float64
-	PeakSNR           float64
-	AvgSNR            float64
-}
-
-func NewSNRCalc() *SNRCalc {
-	alpha := 0.001
-	s := SNRCalc{
-		Y1:     0,
-		Y2:     0,
-		Signal: 0,
-		Noise:  0,
-		Alpha:  alpha,
-		Beta:   1.0 - alpha,
-	}
-	return &s
+	SymbolScale       float32
+	// Counts the symbol batches handed to the decoder, and how often it was too far behind to take them
+	OutputStats queue.Stats
+	stats       atomic.Pointer[DemodulatorStats]
+	snrConf     config.SNRConf
+	snr         *snrTracker
+	// What the pipeline is built from, so Reset can build it again
+	stages []string
+	conf   pipelineConf
+	// Held while a block is being demodulated and handed to the decoder, and by Reset, so a block from
+	// before a Reset can't reach the decoder after it
+	workMutex sync.Mutex
 }
 
-func New(stype radio.StreamType, srate float32, bufsize uint, configFile *koanf.Koanf, decoderInput *chan byte) *Demodulator {
+func New(stype radio.StreamType, srate float32, bufsize uint, configFile *koanf.Koanf, decoderInput *chan []byte) *Demodulator {
 	xritConf := config.XRITConf{
 		SymbolRate:             configFile.Float64("xrit.symbol_rate"),
 		RRCAlpha:               configFile.Float64("xrit.rrc_alpha"),
@@ -75,6 +68,7 @@ func New(stype radio.StreamType, srate float32, bufsize uint, configFile *koanf.
 		Decimation:             configFile.Int("xrit.decimation_factor"),
 		ChunkSize:              uint(configFile.Int("xrit.chunk_size")),
 		DoFFT:                  configFile.Bool("xrit.do_fft"),
+		SymbolScale:            float32(configFile.Float64("xrit.symbol_scale")),
 	}
 	agcConf := config.AGCConf{
 		Rate:      float32(configFile.Float64("agc.rate")),
@@ -83,6 +77,7 @@ func New(stype radio.StreamType, srate float32, bufsize uint, configFile *koanf.
 		MaxGain:   float32(configFile.Float64("agc.max_gain")),
 	}
 	clockConf := config.ClockRecoveryConf{
+		Algorithm:  configFile.String("clockrecovery.algorithm"),
 		Mu:         float32(configFile.Float64("clockrecovery.mu")),
 		Alpha:      float32(configFile.Float64("clockrecovery.alpha")),
 		OmegaLimit: float32(configFile.Float64("clockrecovery.omega_limit")),
@@ -90,89 +85,80 @@ func New(stype radio.StreamType, srate float32, bufsize uint, configFile *koanf.
 
 	log.Debugf("Found xrit definition: %##v", xritConf)
 	log.Debugf("Found agc definition: %##v", agcConf)
-	log.Debugf("Found clock_recovery definition: %##v", clockConf)
-
-	d := Demodulator{
-		SampleInput:       make(chan []complex64, bufsize),
-		SampleType:        stype,
-		SymbolsOutput:     decoderInput,
-		bufferSize:        bufsize,
-		deviceSampleRate:  srate,
-		circuitSampleRate: float32(srate) / float32(xritConf.Decimation),
-		decimFactor:       xritConf.Decimation,
-		sampleChunkSize:   int(xritConf.ChunkSize),
-		gainOmega:         float32((clockConf.Alpha * clockConf.Alpha) / 4.0),
-		DoFFT:             xritConf.DoFFT,
-		SNR:               NewSNRCalc(),
+	costasConf := config.CostasConf{
+		LoopBandwidth: float32(configFile.Float64("costas.loop_bandwidth")),
+		Damping:       float32(configFile.Float64("costas.damping")),
 	}
-	d.sps = d.circuitSampleRate / float32(xritConf.SymbolRate)
 
-	log.Debugf("Setting demodulator values: %##v", d)
-
-	d.AGC = SatHelper.NewAGC(agcConf.Rate, agcConf.Reference, agcConf.Gain, agcConf.MaxGain)
-	d.ClockRecovery = SatHelper.NewClockRecovery(d.sps, (clockConf.Alpha*clockConf.Alpha)/4.0, clockConf.Mu, clockConf.Alpha, clockConf.OmegaLimit)
-	d.RRCFilter = dsp.MakeFirFilter(dsp.MakeRRC(1, float64(srate), xritConf.SymbolRate, xritConf.RRCAlpha, xritConf.RRCTaps))
-	d.Decimator = dsp.MakeDecimationFirFilter(int(xritConf.Decimation), dsp.MakeLowPass(1, float64(srate), float64(d.circuitSampleRate/2)-xritConf.LowPassTransitionWidth/2, xritConf.LowPassTransitionWidth))
-	d.CostasLoop = dsp.MakeCostasLoop2(xritConf.PLLAlpha)
-
-	return &d
-}
-
-func trimSlice(s []complex64) []complex64 {
-	if len(s) > 0 {
-		lastZero := -1
-		for i := len(s) - 1; i >= 0; i-- {
-			if s[i] != 0+0i {
-				break
-			}
-			lastZero = i
-		}
-		if lastZero == -1 {
-			return s
-		}
-		return s[:lastZero+1]
+	dcBlockConf := config.DCBlockConf{
+		Enabled: configFile.Bool("dcblock.enabled"),
+		Rate:    float32(configFile.Float64("dcblock.rate")),
 	}
-	return s
-}
-
-// The SNR calculation routine is based upon SatDump's SNR calculation routine found at:
-// https://github.com/SatDump/SatDump/blob/master/src-core/common/dsp/utils/snr_estimator.cpp
-// Which in turn is based upon the following paper:
-//
-// D. R. Pauluzzi and N. C. Beaulieu, "A comparison of SNR
-// estimation techniques for the AWGN channel," IEEE
-// Trans. Communications, Vol. 48, No. 10, pp. 1681-1691, 2000.
-//
-// TODO Break this out into a separate file, and move to a method on the SNR object
-func (d *Demodulator) GetSNR(s *[]complex64) float64 {
-	for _, samp := range *s {
-		tmp_y1 := math.Pow(cmplx.Abs(complex128(samp)), 2)
-		d.SNR.Y1 = d.SNR.Alpha*tmp_y1 + d.SNR.Beta*d.SNR.Y1
-
-		tmp_y2 := math.Pow(cmplx.Abs(complex128(samp)), 4)
-		d.SNR.Y2 = d.SNR.Alpha*tmp_y2 + d.SNR.Beta*d.SNR.Y2
+	iqConf := config.IQCorrectionConf{
+		Enabled: configFile.Bool("iqcorrection.enabled"),
+		Rate:    float32(configFile.Float64("iqcorrection.rate")),
+	}
+	resamplerConf := config.ResamplerConf{
+		Enabled:          configFile.Bool("resampler.enabled"),
+		SamplesPerSymbol: configFile.Float64("resampler.samples_per_symbol"),
+		MaxInterpolation: configFile.Int("resampler.max_interpolation"),
+	}
+	translateConf := config.TranslateConf{
+		Frequency: float32(configFile.Float64("translate.frequency")),
 	}
+	snrConf := config.SNRConf{
+		Estimator: configFile.String("snr.estimator"),
+		Windows:   snrWindows(configFile),
+		PeakDecay: configFile.Float64("snr.peak_decay"),
+	}
+	stages := pipelineStages(configFile)
+
+	log.Debugf("Found clock_recovery definition: %##v", clockConf)
+	log.Debugf("Found costas definition: %##v", costasConf)
+	log.Debugf("Found dcblock definition: %##v", dcBlockConf)
+	log.Debugf("Found iqcorrection definition: %##v", iqConf)
+	log.Debugf("Found resampler definition: %##v", resamplerConf)
+	log.Debugf("Found translate definition: %##v", translateConf)
+	log.Debugf("Found snr definition: %##v", snrConf)
+	log.Debugf("Found pipeline definition: %v", stages)
 
-	if math.IsNaN(d.SNR.Y1) {
-		d.SNR.Y1 = 0.0
+	d := Demodulator{
+		SampleInput:      make(chan []complex64, bufsize),
+		SampleType:       stype,
+		SymbolsOutput:    decoderInput,
+		bufferSize:       bufsize,
+		deviceSampleRate: srate,
+		decimFactor:      xritConf.Decimation,
+		sampleChunkSize:  int(xritConf.ChunkSize),
+		gainOmega:        float32((clockConf.Alpha * clockConf.Alpha) / 4.0),
+		DoFFT:            xritConf.DoFFT,
+		SymbolScale:      symbolScale(xritConf.SymbolScale, agcConf.Reference),
+		snrConf:          snrConf,
+		snr:              newSNRTracker(snrConf.Windows, snrConf.PeakDecay),
 	}
 
-	if math.IsNaN(d.SNR.Y2) {
-		d.SNR.Y2 = 0.0
+	d.stages = stages
+	d.conf = pipelineConf{
+		xrit:      xritConf,
+		agc:       agcConf,
+		clock:     clockConf,
+		costas:    costasConf,
+		dcBlock:   dcBlockConf,
+		iq:        iqConf,
+		resampler: resamplerConf,
+		translate: translateConf,
 	}
+	d.buildPipeline()
+	d.SNR = d.newSNREstimator()
+	d.CurrentSNR = math.NaN()
 
-	y1_2 := math.Pow(d.SNR.Y1, 2)
-	// Breaking out radicand here to avoid any floating point errors, since
-	// we sqrt it twice
-	radicand := 2.0*y1_2 - d.SNR.Y2
-	d.SNR.Signal = math.Sqrt(radicand)
-	d.SNR.Noise = d.SNR.Y1 - math.Sqrt(radicand)
+	log.Debugf("Setting demodulator values: %##v", &d)
 
-	return max(0, 10.0*math.Log10(d.SNR.Signal/d.SNR.Noise))
+	return &d
 }
 
+// doFFT works out the spectrum in the background. The spectrum stage sets FFTWorking before starting it
 func (d *Demodulator) doFFT(samples []complex64) {
-	d.FFTWorking = true
 	var input []complex128
 
 	for _, sample := range samples {
@@ -205,18 +191,26 @@ func (d *Demodulator) doFFT(samples []complex64) {
 	d.FFTMutex.Unlock()
 }
 
-func (d *Demodulator) Start() {
+// Start demodulates sample blocks until ctx is cancelled or the radio closes SampleInput. Anything still
+// queued is discarded, and SymbolsOutput is closed so the decoder knows nothing more is coming
+func (d *Demodulator) Start(ctx context.Context) {
+	defer close(*d.SymbolsOutput)
 	for {
 		select {
-		case samples := <-d.SampleInput:
-			d.demodBlock(samples)
-		default:
-			time.Sleep(time.Millisecond)
+		case <-ctx.Done():
+			log.Debug("[demod] Stopping")
+			return
+		case samples, ok := <-d.SampleInput:
+			if !ok {
+				log.Debug("[demod] Radio closed, stopping")
+				return
+			}
+			d.demodBlock(ctx, samples)
 		}
 	}
 }
 
-func (d *Demodulator) demodBlock(samples []complex64) {
+func (d *Demodulator) demodBlock(ctx context.Context, samples []complex64) {
 	length := len(samples)
 	input := make([]complex64, length)
 
@@ -228,85 +222,212 @@ func (d *Demodulator) demodBlock(samples []complex64) {
 		input[idx] = sample
 	}
 
-	if d.decimFactor > 1 {
-		log.Debugf("[demod] Running Decimator")
-		input = d.Decimator.Work(input)
-	}
+	d.workMutex.Lock()
+	syncd := d.runPipeline(input)
 
-	//Apply AGC
-	log.Debugf("[demod] Applying AGC")
-	out := make([]complex64, length)
-	d.AGC.Work(&input[0], &out[0], length)
-	out = out[:length]
+	// Update our SNR values in the demodulator
+	d.CurrentSNR = d.SNR.Estimate(samples, syncd)
+	d.snr.add(time.Now(), d.CurrentSNR)
 
-	//Apply Filter
-	log.Debugf("[demod] Applying RRC Filter")
-	out = d.RRCFilter.Work(out)
+	symbols, symbolStats := d.processSymbols(syncd)
+	d.publishStats(symbolStats)
 
-	//Frequency Sync
-	log.Debugf("[demod] Running Costas Loop")
-	out = d.CostasLoop.Work(out)
+	// The whole block's symbols go to the decoder in one go. If it's behind, this waits for it, and so
+	// does Reset
+	queue.Send(ctx, *d.SymbolsOutput, symbols, &d.OutputStats)
+	d.workMutex.Unlock()
+}
 
-	//Clock Sync
-	log.Debugf("[demod] Running Clock Sync (length: %d, mu: %f, omega: %f)", length, d.ClockRecovery.GetMu(), d.ClockRecovery.GetOmega())
+// Reset puts the demodulator back the way it started. The pipeline is built again from the config, which
+// resets the AGC, Costas loop, clock recovery and filters, and the SNR estimate and its stats start over.
+// It's safe to call while Start is running
+func (d *Demodulator) Reset() {
+	d.workMutex.Lock()
+	defer d.workMutex.Unlock()
 
-	syncd := make([]complex64, len(out))
-	numSymbols := d.ClockRecovery.Work(&out[0], &syncd[0], len(out))
+	d.buildPipeline()
+	d.SNR = d.newSNREstimator()
+	d.CurrentSNR = math.NaN()
+	d.snr = newSNRTracker(d.snrConf.Windows, d.snrConf.PeakDecay)
+	d.OutputStats.Reset()
 
-	//Trim out any extra space we have
-	//NOTE: This may or may not be a good idea, but it allows our SNR calculator to actually work
-	//	If this causes an issue with the datalink layer, then lets move the trim to the SNR object
-	syncd = trimSlice(syncd)
+	d.FFTMutex.Lock()
+	d.CurrentFFT = nil
+	d.FFTMutex.Unlock()
 
-	// Update our SNR values in the demodulator
-	snr := d.GetSNR(&syncd)
+	d.publishStats(SymbolStats{Scale: d.SymbolScale})
+}
+
+// ResetPeakSNR starts the SNR peak hold over, e.g. before sweeping the dish again. The rolling SNR stats
+// are left alone
+func (d *Demodulator) ResetPeakSNR() {
+	d.workMutex.Lock()
+	defer d.workMutex.Unlock()
+
+	d.snr.resetPeak()
+}
+
+// processSymbols turns the synchronised symbols into soft symbols for the decoder, along with their stats
+func (d *Demodulator) processSymbols(syncd []complex64) ([]byte, SymbolStats) {
+	var stats SymbolStats
+	symbols := softSymbols(syncd, d.SymbolScale, &stats)
+	return symbols, stats
+}
 
-	if snr > d.PeakSNR {
-		d.PeakSNR = snr
+// newCostasLoop builds the second order Costas loop from the costas block, falling back to the old
+// xrit.pll_alpha setting (the loop bandwidth, with the default damping) for older config files
+func newCostasLoop(conf config.CostasConf, pllAlpha float32) dsp.CostasLoop {
+	bandwidth := conf.LoopBandwidth
+	if bandwidth == 0 {
+		if pllAlpha == 0 {
+			log.Fatal("No Costas loop bandwidth set; please set costas.loop_bandwidth")
+		}
+		log.Warn("xrit.pll_alpha is deprecated; please set costas.loop_bandwidth instead")
+		bandwidth = pllAlpha
 	}
 
-	//To avoid strange NaN's for average
-	if snr > 0 {
-		d.AvgSNR += snr
-		d.AvgSNR /= 2
+	loop := dsp.MakeCostasLoop2(bandwidth)
+	if conf.Damping != 0 {
+		if err := loop.(*dsp.CostasLoop2).SetDampingFactor(conf.Damping); err != nil {
+			log.Fatalf("Invalid costas.damping: %v", err)
+		}
 	}
+	return loop
+}
 
-	d.CurrentSNR = snr
+// newResampler designs a polyphase resampler that takes the decimated sample rate to as close to
+// resampler.samples_per_symbol as it can get with an interpolation of at most resampler.max_interpolation.
+// The anti-aliasing filter passes the whole RRC shaped signal, and stops at the lower of the two rates'
+// Nyquist frequencies
+func newResampler(conf config.ResamplerConf, inputRate float32, xritConf config.XRITConf) *RationalResampler {
+	if conf.SamplesPerSymbol <= 0 {
+		log.Fatal("resampler.samples_per_symbol must be greater than 0")
+	}
+	if conf.MaxInterpolation <= 0 {
+		conf.MaxInterpolation = 64
+	}
+
+	targetRate := conf.SamplesPerSymbol * xritConf.SymbolRate
+	interpolation, decimation := rationalApprox(targetRate/float64(inputRate), conf.MaxInterpolation)
+	outputRate := float64(inputRate) * float64(interpolation) / float64(decimation)
 
-	// Do the FFT things
-	d.FFTMutex.RLock()
-	if d.DoFFT && !d.FFTWorking {
-		d.FFTMutex.RUnlock()
-		go d.doFFT(out)
-	} else {
-		d.FFTMutex.RUnlock()
+	passBand := xritConf.SymbolRate * (1 + xritConf.RRCAlpha) / 2
+	stopBand := min(float64(inputRate), outputRate) / 2
+	if stopBand <= passBand {
+		log.Fatalf("Resampling from %.0f to %.0f samples/s would cut off the signal; raise resampler.samples_per_symbol", inputRate, outputRate)
 	}
 
-	symbols := d.processSymbols(syncd, numSymbols)
+	log.Infof("[demod] Resampling from %.0f to %.0f samples/s (%d/%d, %.3f samples per symbol)", inputRate, outputRate, interpolation, decimation, outputRate/xritConf.SymbolRate)
+	taps := dsp.MakeLowPass(float64(interpolation), float64(inputRate)*float64(interpolation), (passBand+stopBand)/2, stopBand-passBand)
+	return NewRationalResampler(interpolation, decimation, taps)
+}
+
+// pipelineConf holds the config blocks of every stage the pipeline can be built from
+type pipelineConf struct {
+	xrit      config.XRITConf
+	agc       config.AGCConf
+	clock     config.ClockRecoveryConf
+	costas    config.CostasConf
+	dcBlock   config.DCBlockConf
+	iq        config.IQCorrectionConf
+	resampler config.ResamplerConf
+	translate config.TranslateConf
+}
 
-	for _, symbol := range symbols {
-		if !d.Stopping {
-			*d.SymbolsOutput <- symbol
+// buildPipeline builds the stages listed in pipeline.stages, replacing any that were there before
+func (d *Demodulator) buildPipeline() {
+	d.Pipeline = nil
+	d.ClockRecovery = nil
+	rate := d.deviceSampleRate
+	for _, name := range d.stages {
+		var stage Stage
+		stage, rate = d.newStage(name, rate, d.conf)
+		if stage != nil {
+			d.Pipeline = append(d.Pipeline, stage)
 		}
 	}
+	if d.ClockRecovery == nil {
+		log.Fatal("pipeline.stages must include clockrecovery")
+	}
 }
 
-func (d *Demodulator) processSymbols(ob []complex64, numSymbols int) []byte {
-	symbols := make([]byte, numSymbols)
-	for i := 0; i < numSymbols; i++ {
-		val := ob[i]
-		sym := real(val) * 127
-		if sym > 127 {
-			sym = 127
-		} else if sym < -128 {
-			sym = -128
+// newStage builds the named stage for samples arriving at rate, returning it along with the rate of its
+// output. Stages that are turned off come back nil
+func (d *Demodulator) newStage(name string, rate float32, conf pipelineConf) (Stage, float32) {
+	switch name {
+	case "dcblock":
+		if !conf.dcBlock.Enabled {
+			return nil, rate
+		}
+		d.DCBlocker = NewDCBlocker(conf.dcBlock.Rate)
+		return &dcBlockStage{d.DCBlocker}, rate
+
+	case "iqcorrection":
+		if !conf.iq.Enabled {
+			return nil, rate
+		}
+		d.IQCorrector = NewIQCorrector(conf.iq.Rate)
+		return &iqCorrectionStage{d.IQCorrector}, rate
+
+	case "spectrum":
+		if !d.DoFFT {
+			return nil, rate
 		}
-		symbols[i] = byte(sym)
+		return &spectrumStage{d, rate}, rate
+
+	case "translate":
+		if conf.translate.Frequency == 0 {
+			return nil, rate
+		}
+		d.Translator = dsp.MakeRotatorWithFrequency(conf.translate.Frequency, rate)
+		return &translateStage{d.Translator, conf.translate.Frequency}, rate
+
+	case "decimator":
+		if conf.xrit.Decimation <= 1 {
+			return nil, rate
+		}
+		out := rate / float32(conf.xrit.Decimation)
+		taps := dsp.MakeLowPass(1, float64(rate), float64(out/2)-conf.xrit.LowPassTransitionWidth/2, conf.xrit.LowPassTransitionWidth)
+		d.Decimator = dsp.MakeDecimationFirFilter(conf.xrit.Decimation, taps)
+		return &firStage{"decimator", d.Decimator, len(taps)}, out
+
+	case "resampler":
+		if !conf.resampler.Enabled {
+			return nil, rate
+		}
+		d.Resampler = newResampler(conf.resampler, rate, conf.xrit)
+		out := rate * float32(d.Resampler.GetInterpolation()) / float32(d.Resampler.GetDecimation())
+		return &resamplerStage{d.Resampler}, out
+
+	case "agc":
+		d.AGC = NewAGC(conf.agc.Rate, conf.agc.Reference, conf.agc.Gain, conf.agc.MaxGain)
+		return &agcStage{d.AGC}, rate
+
+	case "rrc":
+		taps := dsp.MakeRRC(1, float64(rate), conf.xrit.SymbolRate, conf.xrit.RRCAlpha, conf.xrit.RRCTaps)
+		d.RRCFilter = dsp.MakeFirFilter(taps)
+		return &firStage{"rrc", d.RRCFilter, len(taps)}, rate
+
+	case "costas":
+		d.CostasLoop = newCostasLoop(conf.costas, conf.xrit.PLLAlpha)
+		return &costasStage{d.CostasLoop, rate}, rate
+
+	case "clockrecovery":
+		d.circuitSampleRate = rate
+		d.sps = rate / float32(conf.xrit.SymbolRate)
+		gainOmega := (conf.clock.Alpha * conf.clock.Alpha) / 4.0
+		switch conf.clock.Algorithm {
+		case "gardner":
+			d.ClockRecovery = NewGardnerRecovery(d.sps, gainOmega, conf.clock.Mu, conf.clock.Alpha, conf.clock.OmegaLimit)
+		case "mm", "":
+			d.ClockRecovery = NewClockRecovery(d.sps, gainOmega, conf.clock.Mu, conf.clock.Alpha, conf.clock.OmegaLimit)
+		default:
+			log.Fatalf("Unknown clock recovery algorithm %q; expected \"mm\" or \"gardner\"", conf.clock.Algorithm)
+		}
+		// Everything after clock recovery sees one sample per symbol
+		return &clockRecoveryStage{d.ClockRecovery}, float32(conf.xrit.SymbolRate)
 	}
-	return symbols
-}
 
-func (d *Demodulator) Close() {
-	d.Stopping = true
-	close(*d.SymbolsOutput)
+	log.Fatalf("Unknown demodulator stage %q in pipeline.stages", name)
+	return nil, rate
 }
@@ -0,0 +1,72 @@
+package demod
+
+import "math"
+
+// DCBlocker removes the DC offset many SDRs (RTL-SDR in particular) leave at the centre of the band, by
+// tracking the mean of the samples with a single pole average and subtracting it
+type DCBlocker struct {
+	rate   float32
+	offset complex64
+}
+
+func NewDCBlocker(rate float32) *DCBlocker {
+	return &DCBlocker{rate: rate}
+}
+
+// GetOffset returns the DC offset currently being removed
+func (b *DCBlocker) GetOffset() complex64 {
+	return b.offset
+}
+
+func (b *DCBlocker) Work(input []complex64, output []complex64) {
+	for i, sample := range input {
+		b.offset += complex(b.rate, 0) * (sample - b.offset)
+		output[i] = sample - b.offset
+	}
+}
+
+// IQCorrector blindly corrects amplitude and phase imbalance between the I and Q channels. It tracks the
+// power of each channel and their correlation, which should be equal and zero respectively, then
+// removes the part of Q that correlates with I and scales what's left to match I's power
+type IQCorrector struct {
+	rate   float32
+	iPower float32
+	qPower float32
+	iq     float32
+}
+
+func NewIQCorrector(rate float32) *IQCorrector {
+	return &IQCorrector{rate: rate, iPower: 1, qPower: 1}
+}
+
+// GetImbalance returns the estimated amplitude imbalance in dB, and phase imbalance in degrees
+func (c *IQCorrector) GetImbalance() (float32, float32) {
+	if c.iPower <= 0 || c.qPower <= 0 {
+		return 0, 0
+	}
+	amplitude := 10 * math.Log10(float64(c.qPower/c.iPower))
+	phase := math.Asin(float64(clip(c.iq/float32(math.Sqrt(float64(c.iPower*c.qPower))), 1))) * 180 / math.Pi
+	return float32(amplitude), float32(phase)
+}
+
+func (c *IQCorrector) Work(input []complex64, output []complex64) {
+	for idx, sample := range input {
+		i, q := real(sample), imag(sample)
+		c.iPower += c.rate * (i*i - c.iPower)
+		c.qPower += c.rate * (q*q - c.qPower)
+		c.iq += c.rate * (i*q - c.iq)
+
+		if c.iPower <= 0 {
+			output[idx] = sample
+			continue
+		}
+		leak := c.iq / c.iPower
+		residual := c.qPower - c.iq*leak
+		if residual <= 0 {
+			output[idx] = sample
+			continue
+		}
+		scale := float32(math.Sqrt(float64(c.iPower / residual)))
+		output[idx] = complex(i, (q-leak*i)*scale)
+	}
+}
@@ -0,0 +1,88 @@
+package demod
+
+import (
+	"math"
+)
+
+// GardnerRecovery is a Gardner symbol timing recovery loop. Unlike Mueller and Müller it doesn't depend
+// on symbol decisions, so it can pull in before the Costas loop has settled, at the cost of needing a
+// second interpolated sample halfway between each pair of symbols
+type GardnerRecovery struct {
+	mu                 float32
+	omega              float32
+	gainOmega          float32
+	gainMu             float32
+	omegaRelativeLimit float32
+	omegaMid           float32
+	omegaLimit         float32
+
+	interp *interpolator
+	// History always starts this many samples before the next symbol, so there's room to look back
+	// for the midpoint
+	lookback int
+	history  []complex64
+	last     complex64
+}
+
+func NewGardnerRecovery(omega float32, gainOmega float32, mu float32, gainMu float32, omegaRelativeLimit float32) *GardnerRecovery {
+	g := GardnerRecovery{
+		mu:                 mu,
+		gainOmega:          gainOmega,
+		gainMu:             gainMu,
+		omegaRelativeLimit: omegaRelativeLimit,
+		interp:             newInterpolator(),
+	}
+	g.SetOmega(omega)
+	g.lookback = int(math.Ceil(float64(g.omegaMid+g.omegaLimit))) + 1
+	g.history = make([]complex64, g.lookback)
+	return &g
+}
+
+func (g *GardnerRecovery) GetMu() float32 {
+	return g.mu
+}
+
+func (g *GardnerRecovery) GetOmega() float32 {
+	return g.omega
+}
+
+// SetOmega sets the nominal samples per symbol, which the loop is kept within omegaRelativeLimit of
+func (g *GardnerRecovery) SetOmega(omega float32) {
+	g.omega = omega
+	g.omegaMid = omega
+	g.omegaLimit = g.omegaRelativeLimit * omega
+}
+
+// Work returns the symbols recovered from input. Samples it couldn't use yet are kept for the next call
+func (g *GardnerRecovery) Work(input []complex64) []complex64 {
+	samples := append(g.history, input...)
+	output := make([]complex64, 0, int(float32(len(samples))/g.omega)+1)
+
+	idx := g.lookback
+	end := len(samples) - interpTaps - 16
+	for idx < end {
+		sym := g.interp.interpolate(samples[idx:], g.mu)
+
+		// The sample halfway back to the previous symbol
+		midPos := float64(idx) + float64(g.mu) - float64(g.omega)/2
+		midIdx := math.Floor(midPos)
+		mid := g.interp.interpolate(samples[int(midIdx):], float32(midPos-midIdx))
+
+		// If we're sampling late, the midpoint has moved towards the current symbol and picks up its sign
+		ted := clip(real((sym-g.last)*conj(mid)), 1.0)
+		g.last = sym
+		output = append(output, sym)
+
+		g.omega -= g.gainOmega * ted
+		g.omega = g.omegaMid + clip(g.omega-g.omegaMid, g.omegaLimit)
+
+		g.mu += g.omega - g.gainMu*ted
+		step := float32(math.Floor(float64(g.mu)))
+		idx += int(step)
+		g.mu -= step
+	}
+
+	g.history = append(g.history[:0:0], samples[idx-g.lookback:]...)
+
+	return output
+}
@@ -0,0 +1,68 @@
+package demod
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/knadh/koanf/v2"
+)
+
+// Stage is one step of the demodulator's DSP chain. The stages are run in the order they're listed in
+// pipeline.stages, each one working on the previous one's output
+type Stage interface {
+	// Name is the name the stage is listed under in pipeline.stages
+	Name() string
+	// Process runs a block of samples through the stage. It may work in place, and its output can be a
+	// different length (and sample rate) to its input
+	Process(input []complex64) []complex64
+	// Stats reports what the stage is currently doing, for display
+	Stats() []StageStat
+}
+
+type StageStat struct {
+	Name  string
+	Value float64
+}
+
+// StageStats is a snapshot of a single stage's stats
+type StageStats struct {
+	Stage string
+	Stats []StageStat
+}
+
+// DefaultPipeline is the stage order used when pipeline.stages isn't set. Stages that are turned off in
+// their own config block are skipped
+var DefaultPipeline = []string{"dcblock", "iqcorrection", "spectrum", "translate", "decimator", "resampler", "agc", "rrc", "costas", "clockrecovery"}
+
+// pipelineStages reads the stage order from the config. From a config file it's a list, but from an
+// environment variable it's a comma or space separated string
+func pipelineStages(configFile *koanf.Koanf) []string {
+	stages := configFile.Strings("pipeline.stages")
+	if len(stages) == 0 {
+		stages = strings.FieldsFunc(configFile.String("pipeline.stages"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	if len(stages) == 0 {
+		return DefaultPipeline
+	}
+	return stages
+}
+
+// runPipeline passes a block of samples through every stage in turn
+func (d *Demodulator) runPipeline(input []complex64) []complex64 {
+	for _, stage := range d.Pipeline {
+		log.Debugf("[demod] Running %s", stage.Name())
+		input = stage.Process(input)
+	}
+	return input
+}
+
+// pipelineStats snapshots the stats of every stage
+func (d *Demodulator) pipelineStats() []StageStats {
+	stats := make([]StageStats, len(d.Pipeline))
+	for i, stage := range d.Pipeline {
+		stats[i] = StageStats{Stage: stage.Name(), Stats: stage.Stats()}
+	}
+	return stats
+}
@@ -0,0 +1,95 @@
+package demod
+
+import "math"
+
+// RationalResampler is a polyphase resampler that changes the sample rate by interpolation/decimation.
+// Rather than zero stuffing and filtering at the interpolated rate, it only works out the filter
+// phases for the samples it keeps
+type RationalResampler struct {
+	interpolation int
+	decimation    int
+	// phases[p][k] is tap p + k*interpolation of the prototype filter
+	phases  [][]float32
+	history []complex64
+	// Position of the next output sample in the interpolated stream, relative to the start of history
+	next int
+}
+
+// NewRationalResampler builds a resampler from a low pass prototype filter designed at the
+// interpolated rate, with a gain of interpolation to make up for the zero stuffing
+func NewRationalResampler(interpolation int, decimation int, taps []float32) *RationalResampler {
+	tapsPerPhase := (len(taps) + interpolation - 1) / interpolation
+	phases := make([][]float32, interpolation)
+	for p := range phases {
+		phases[p] = make([]float32, tapsPerPhase)
+		for k := range phases[p] {
+			if i := p + k*interpolation; i < len(taps) {
+				phases[p][k] = taps[i]
+			}
+		}
+	}
+
+	return &RationalResampler{
+		interpolation: interpolation,
+		decimation:    decimation,
+		phases:        phases,
+		history:       make([]complex64, tapsPerPhase-1),
+		next:          (tapsPerPhase - 1) * interpolation,
+	}
+}
+
+func (r *RationalResampler) GetInterpolation() int {
+	return r.interpolation
+}
+
+func (r *RationalResampler) GetDecimation() int {
+	return r.decimation
+}
+
+func (r *RationalResampler) Work(input []complex64) []complex64 {
+	buf := append(r.history, input...)
+	output := make([]complex64, 0, len(input)*r.interpolation/r.decimation+1)
+
+	for ; r.next/r.interpolation < len(buf); r.next += r.decimation {
+		idx := r.next / r.interpolation
+		var acc complex64
+		for k, tap := range r.phases[r.next%r.interpolation] {
+			acc += buf[idx-k] * complex(tap, 0)
+		}
+		output = append(output, acc)
+	}
+
+	// Keep enough of the input around for the first output samples of the next block
+	shift := len(buf) - len(r.history)
+	r.history = append(r.history[:0], buf[shift:]...)
+	r.next -= shift * r.interpolation
+
+	return output
+}
+
+// rationalApprox finds the fraction closest to x whose numerator is no larger than maxNumerator, by
+// walking the convergents of x's continued fraction
+func rationalApprox(x float64, maxNumerator int) (int, int) {
+	// Convergents h/k, seeded with 1/0 and 0/1
+	h0, h1 := 0, 1
+	k0, k1 := 1, 0
+	rest := x
+	for {
+		a := int(math.Floor(rest))
+		h2, k2 := a*h1+h0, a*k1+k0
+		if h2 > maxNumerator {
+			break
+		}
+		h0, h1 = h1, h2
+		k0, k1 = k1, k2
+		frac := rest - float64(a)
+		if frac < 1e-9 {
+			break
+		}
+		rest = 1 / frac
+	}
+	if h1 == 0 || k1 == 0 {
+		return 1, max(1, int(math.Round(1/x)))
+	}
+	return h1, k1
+}
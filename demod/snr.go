@@ -0,0 +1,250 @@
+package demod
+
+import (
+	"math"
+	"math/cmplx"
+	"slices"
+
+	"github.com/charmbracelet/log"
+	"github.com/racerxdl/segdsp/dsp"
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// SNREstimators lists the estimators snr.estimator can select
+var SNREstimators = []string{"m2m4", "dd", "spectral"}
+
+// SNREstimator estimates the Es/N0 of the signal, in dB
+type SNREstimator interface {
+	// Name is the name the estimator is selected by in snr.estimator
+	Name() string
+	// Estimate works out the Es/N0 from a block of samples as they came from the radio, and the symbols
+	// the pipeline recovered from them. It's NaN when the estimator can't make an estimate, e.g. when the
+	// signal is too far down in the noise for it
+	Estimate(samples []complex64, symbols []complex64) float64
+}
+
+// toDB turns a power ratio into dB. A ratio that isn't positive means there's no estimate, so gives NaN
+func toDB(ratio float64) float64 {
+	if ratio <= 0 || math.IsNaN(ratio) {
+		return math.NaN()
+	}
+	return 10 * math.Log10(ratio)
+}
+
+// CN0 converts an Es/N0 in dB to a carrier to noise density ratio in dB-Hz. No Es/N0 gives no C/N0
+func CN0(esn0 float64, symbolRate float64) float64 {
+	return esn0 + 10*math.Log10(symbolRate)
+}
+
+// The SNR calculation routine is based upon SatDump's SNR calculation routine found at:
+// https://github.com/SatDump/SatDump/blob/master/src-core/common/dsp/utils/snr_estimator.cpp
+// Which in turn is based upon the following paper:
+//
+// D. R. Pauluzzi and N. C. Beaulieu, "A comparison of SNR
+// estimation techniques for the AWGN channel," IEEE
+// Trans. Communications, Vol. 48, No. 10, pp. 1681-1691, 2000.
+type SNRCalc struct {
+	Y1     float64
+	Y2     float64
+	Alpha  float64
+	Beta   float64
+	Signal float64
+	Noise  float64
+}
+
+func NewSNRCalc() *SNRCalc {
+	alpha := 0.001
+	s := SNRCalc{
+		Y1:     0,
+		Y2:     0,
+		Signal: 0,
+		Noise:  0,
+		Alpha:  alpha,
+		Beta:   1.0 - alpha,
+	}
+	return &s
+}
+
+func (s *SNRCalc) Name() string { return "m2m4" }
+
+// Estimate is the M2M4 estimator: it works out the signal and noise power from the second and fourth
+// moments of the symbols' magnitude. It needs no decisions, but falls apart below about 0 dB, where the
+// radicand goes negative and there's no estimate to be had
+func (s *SNRCalc) Estimate(samples []complex64, symbols []complex64) float64 {
+	for _, samp := range symbols {
+		tmp_y1 := math.Pow(cmplx.Abs(complex128(samp)), 2)
+		s.Y1 = s.Alpha*tmp_y1 + s.Beta*s.Y1
+
+		tmp_y2 := math.Pow(cmplx.Abs(complex128(samp)), 4)
+		s.Y2 = s.Alpha*tmp_y2 + s.Beta*s.Y2
+	}
+
+	if math.IsNaN(s.Y1) {
+		s.Y1 = 0.0
+	}
+
+	if math.IsNaN(s.Y2) {
+		s.Y2 = 0.0
+	}
+
+	y1_2 := math.Pow(s.Y1, 2)
+	// Breaking out radicand here to avoid any floating point errors, since
+	// we sqrt it twice
+	radicand := 2.0*y1_2 - s.Y2
+	if radicand <= 0 {
+		s.Signal, s.Noise = 0, s.Y1
+		return math.NaN()
+	}
+	s.Signal = math.Sqrt(radicand)
+	s.Noise = s.Y1 - math.Sqrt(radicand)
+
+	return toDB(s.Signal / s.Noise)
+}
+
+// DecisionDirectedSNR estimates the Es/N0 of BPSK symbols by deciding which symbol each one is meant to
+// be, and treating whatever's left over as noise. It's accurate down to a few dB below 0, below which
+// wrong decisions start to flatter it
+type DecisionDirectedSNR struct {
+	Signal float64
+	Noise  float64
+}
+
+func NewDecisionDirectedSNR() *DecisionDirectedSNR {
+	return &DecisionDirectedSNR{}
+}
+
+func (s *DecisionDirectedSNR) Name() string { return "dd" }
+
+func (s *DecisionDirectedSNR) Estimate(samples []complex64, symbols []complex64) float64 {
+	if len(symbols) == 0 {
+		return toDB(s.Signal / s.Noise)
+	}
+
+	// The symbols should sit at ±amplitude on the real axis
+	var amplitude float64
+	for _, sym := range symbols {
+		amplitude += math.Abs(float64(real(sym)))
+	}
+	amplitude /= float64(len(symbols))
+
+	var noise float64
+	for _, sym := range symbols {
+		i, q := math.Abs(float64(real(sym)))-amplitude, float64(imag(sym))
+		noise += i*i + q*q
+	}
+	noise /= float64(len(symbols))
+
+	s.Signal = amplitude * amplitude
+	s.Noise = noise
+	return toDB(s.Signal / s.Noise)
+}
+
+// Size of the FFTs the spectral estimator averages
+const spectralFFTSize = 1024
+
+// SpectralSNR estimates the Es/N0 from the spectrum of the samples coming from the radio: the noise density
+// is taken from either side of the signal, and the carrier power is what's left in the signal's bandwidth
+// after taking the noise out. It doesn't need the demodulator to be locked, so it works even while the
+// dish is well off the satellite
+type SpectralSNR struct {
+	sampleRate float64
+	symbolRate float64
+	fft        *fourier.CmplxFFT
+	window     []float64
+	// Power spectral density, averaged over a few blocks
+	psd []float64
+	// The FFT bins the signal and the noise floor are measured in
+	signalBins []int
+	noiseBins  []int
+}
+
+// NewSpectralSNR builds a spectral estimator for a signal centred frequency Hz from the middle of the
+// radio's band, with a bandwidth of symbolRate*(1+rolloff)
+func NewSpectralSNR(sampleRate float64, symbolRate float64, rolloff float64, frequency float64) *SpectralSNR {
+	s := SpectralSNR{
+		sampleRate: sampleRate,
+		symbolRate: symbolRate,
+		fft:        fourier.NewCmplxFFT(spectralFFTSize),
+		window:     dsp.HammingWindow(spectralFFTSize),
+	}
+
+	// Keep the noise measurement clear of the signal's skirts, and of the radio's anti-aliasing filter
+	halfBandwidth := symbolRate * (1 + rolloff) / 2
+	for i := 0; i < spectralFFTSize; i++ {
+		freq := (float64(i)/spectralFFTSize - 0.5) * sampleRate
+		offset := math.Abs(freq - frequency)
+		switch {
+		case offset <= halfBandwidth:
+			s.signalBins = append(s.signalBins, i)
+		case offset > halfBandwidth*1.1 && math.Abs(freq) < 0.45*sampleRate:
+			s.noiseBins = append(s.noiseBins, i)
+		}
+	}
+	if len(s.noiseBins) == 0 {
+		log.Fatalf("The spectral SNR estimator needs some spectrum either side of the signal; raise radio.sample_rate above %.0f", 2*halfBandwidth*1.1/0.9)
+	}
+	return &s
+}
+
+func (s *SpectralSNR) Name() string { return "spectral" }
+
+func (s *SpectralSNR) Estimate(samples []complex64, symbols []complex64) float64 {
+	segments := len(samples) / spectralFFTSize
+	if segments > 0 {
+		psd := make([]float64, spectralFFTSize)
+		segment := make([]complex128, spectralFFTSize)
+		coeff := make([]complex128, spectralFFTSize)
+		for n := 0; n < segments; n++ {
+			for i := range segment {
+				segment[i] = complex128(samples[n*spectralFFTSize+i]) * complex(s.window[i], 0)
+			}
+			s.fft.Coefficients(coeff, segment)
+			for i := range psd {
+				c := coeff[s.fft.ShiftIdx(i)]
+				psd[i] += (real(c)*real(c) + imag(c)*imag(c)) / float64(segments)
+			}
+		}
+
+		if s.psd == nil {
+			s.psd = psd
+		} else {
+			for i := range psd {
+				s.psd[i] += 0.1 * (psd[i] - s.psd[i])
+			}
+		}
+	}
+	if s.psd == nil {
+		return math.NaN()
+	}
+
+	// The median keeps any other signals that happen to be nearby out of the noise floor
+	noise := make([]float64, len(s.noiseBins))
+	for i, bin := range s.noiseBins {
+		noise[i] = s.psd[bin]
+	}
+	slices.Sort(noise)
+	noiseDensity := noise[len(noise)/2]
+
+	var carrier float64
+	for _, bin := range s.signalBins {
+		carrier += s.psd[bin] - noiseDensity
+	}
+
+	// Es/N0 is the carrier to noise ratio in a bandwidth of the symbol rate
+	binWidth := s.sampleRate / spectralFFTSize
+	return toDB(carrier * binWidth / (noiseDensity * s.symbolRate))
+}
+
+// newSNREstimator builds the estimator selected by snr.estimator
+func (d *Demodulator) newSNREstimator() SNREstimator {
+	switch d.snrConf.Estimator {
+	case "m2m4", "":
+		return NewSNRCalc()
+	case "dd":
+		return NewDecisionDirectedSNR()
+	case "spectral":
+		return NewSpectralSNR(float64(d.deviceSampleRate), d.conf.xrit.SymbolRate, d.conf.xrit.RRCAlpha, float64(d.conf.translate.Frequency))
+	}
+	log.Fatalf("Unknown SNR estimator %q; expected one of %q", d.snrConf.Estimator, SNREstimators)
+	return nil
+}
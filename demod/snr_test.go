@@ -0,0 +1,74 @@
+package demod
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// An arbitrary time for the SNR readings in tests to start from
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// bpskSymbols returns BPSK symbols with unit energy and Gaussian noise at the given Es/N0
+func bpskSymbols(rng *rand.Rand, n int, esn0 float64) []complex64 {
+	sigma := math.Sqrt(math.Pow(10, -esn0/10) / 2)
+	symbols := make([]complex64, n)
+	for i := range symbols {
+		bit := float64(2*rng.IntN(2) - 1)
+		symbols[i] = complex(float32(bit+rng.NormFloat64()*sigma), float32(rng.NormFloat64()*sigma))
+	}
+	return symbols
+}
+
+func TestSNREstimators(t *testing.T) {
+	tests := []struct {
+		esn0      float64
+		tolerance float64
+	}{
+		{0, 1},
+		{5, 0.5},
+		{10, 0.5},
+		{15, 0.5},
+	}
+	for _, tt := range tests {
+		for _, estimator := range []SNREstimator{NewSNRCalc(), NewDecisionDirectedSNR()} {
+			rng := rand.New(rand.NewPCG(47, uint64(tt.esn0)))
+			var got float64
+			// M2M4 averages over a few thousand symbols, so give it a few blocks to settle
+			for i := 0; i < 10; i++ {
+				got = estimator.Estimate(nil, bpskSymbols(rng, 4096, tt.esn0))
+			}
+			if math.Abs(got-tt.esn0) > tt.tolerance {
+				t.Errorf("%s at %.0f dB: got %.2f dB", estimator.Name(), tt.esn0, got)
+			}
+		}
+	}
+}
+
+func TestM2M4NoEstimate(t *testing.T) {
+	// Well down in the noise, the fourth moment outgrows twice the square of the second, and there's no
+	// estimate to be had
+	s := NewSNRCalc()
+	s.Y1, s.Y2 = 1, 2.5
+	got := s.Estimate(nil, nil)
+	if !math.IsNaN(got) {
+		t.Errorf("got %.2f dB with a negative radicand, want NaN", got)
+	}
+	if cn0 := CN0(got, 927000); !math.IsNaN(cn0) {
+		t.Errorf("got a C/N0 of %.2f dB-Hz without an SNR", cn0)
+	}
+}
+
+func TestSNRTrackerSkipsNoEstimate(t *testing.T) {
+	tracker := newSNRTracker(nil, 0)
+	tracker.add(testStart, 5)
+	tracker.add(testStart.Add(time.Second), math.NaN())
+	stats := tracker.stats()
+	if session := stats[len(stats)-1]; session.Count != 1 || session.Mean != 5 {
+		t.Errorf("session stats %+v, want just the one reading of 5 dB", session)
+	}
+	if tracker.peak.Peak != 5 {
+		t.Errorf("peak %.2f, want 5", tracker.peak.Peak)
+	}
+}
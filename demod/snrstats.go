@@ -0,0 +1,197 @@
+package demod
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/knadh/koanf/v2"
+)
+
+// DefaultSNRWindows are the windows the SNR is summarised over when snr.windows isn't set. The whole
+// session is always summarised as well
+var DefaultSNRWindows = []time.Duration{5 * time.Second, time.Minute}
+
+// SNRWindowStats summarises the SNR over a window of time
+type SNRWindowStats struct {
+	// Window is how far back the stats go. Zero is the whole session (since the last reset)
+	Window time.Duration
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+}
+
+// PeakHold is the best SNR seen since the last reset, along with a held value that decays away from it
+// so a peak from an old dish position doesn't hang around forever
+type PeakHold struct {
+	// Peak is the best SNR since the last reset, and Time is when it was seen
+	Peak float64
+	Time time.Time
+	// Held is the peak hold value: it jumps up to any new high, then decays at snr.peak_decay dB/s
+	Held float64
+}
+
+type snrSample struct {
+	time  time.Time
+	value float64
+}
+
+// snrWindow keeps the SNR readings from the last window of time. A zero window keeps running sums over
+// the whole session instead of the readings themselves
+type snrWindow struct {
+	window  time.Duration
+	samples []snrSample
+	// Running totals, only used for the session window
+	count          int
+	sum, sumSquare float64
+	min, max       float64
+}
+
+func (w *snrWindow) add(now time.Time, snr float64) {
+	if w.window > 0 {
+		w.samples = append(w.samples, snrSample{now, snr})
+		w.expire(now)
+		return
+	}
+
+	if w.count == 0 || snr < w.min {
+		w.min = snr
+	}
+	if w.count == 0 || snr > w.max {
+		w.max = snr
+	}
+	w.count++
+	w.sum += snr
+	w.sumSquare += snr * snr
+}
+
+// expire drops the readings that are more than the window old
+func (w *snrWindow) expire(now time.Time) {
+	expired := 0
+	for expired < len(w.samples) && now.Sub(w.samples[expired].time) > w.window {
+		expired++
+	}
+	w.samples = w.samples[expired:]
+}
+
+func (w *snrWindow) stats() SNRWindowStats {
+	stats := SNRWindowStats{Window: w.window}
+	if w.window > 0 {
+		for i, sample := range w.samples {
+			if i == 0 || sample.value < stats.Min {
+				stats.Min = sample.value
+			}
+			if i == 0 || sample.value > stats.Max {
+				stats.Max = sample.value
+			}
+			stats.Mean += sample.value
+		}
+		stats.Count = len(w.samples)
+		if stats.Count == 0 {
+			return stats
+		}
+		stats.Mean /= float64(stats.Count)
+		for _, sample := range w.samples {
+			stats.StdDev += (sample.value - stats.Mean) * (sample.value - stats.Mean)
+		}
+		stats.StdDev = math.Sqrt(stats.StdDev / float64(stats.Count))
+		return stats
+	}
+
+	stats.Count, stats.Min, stats.Max = w.count, w.min, w.max
+	if w.count == 0 {
+		return stats
+	}
+	stats.Mean = w.sum / float64(w.count)
+	stats.StdDev = math.Sqrt(max(0, w.sumSquare/float64(w.count)-stats.Mean*stats.Mean))
+	return stats
+}
+
+// snrTracker keeps the rolling SNR stats and peak hold. It's only touched with the demodulator's
+// workMutex held
+type snrTracker struct {
+	windows []*snrWindow
+	// How quickly the held peak decays, in dB/s
+	decay float64
+	peak  PeakHold
+	last  time.Time
+}
+
+func newSNRTracker(windows []time.Duration, decay float64) *snrTracker {
+	t := &snrTracker{decay: decay}
+	for _, window := range windows {
+		t.windows = append(t.windows, &snrWindow{window: window})
+	}
+	// The session window
+	t.windows = append(t.windows, &snrWindow{})
+	return t
+}
+
+// add records an SNR reading. Blocks the estimator couldn't make anything of are left out of the stats,
+// but time still moves on for them: old readings drop out of the windows and the held peak decays, so
+// neither hangs on to the last good reading while the signal is gone
+func (t *snrTracker) add(now time.Time, snr float64) {
+	if !t.last.IsZero() {
+		t.peak.Held -= t.decay * now.Sub(t.last).Seconds()
+		t.last = now
+	}
+	if math.IsNaN(snr) {
+		for _, window := range t.windows {
+			window.expire(now)
+		}
+		return
+	}
+	for _, window := range t.windows {
+		window.add(now, snr)
+	}
+
+	if t.peak.Time.IsZero() || snr > t.peak.Peak {
+		t.peak.Peak = snr
+		t.peak.Time = now
+	}
+	if t.last.IsZero() || snr > t.peak.Held {
+		t.peak.Held = snr
+	}
+	t.last = now
+}
+
+// resetPeak starts the peak hold over from the next reading, leaving the windows alone
+func (t *snrTracker) resetPeak() {
+	t.peak = PeakHold{}
+	t.last = time.Time{}
+}
+
+func (t *snrTracker) stats() []SNRWindowStats {
+	stats := make([]SNRWindowStats, len(t.windows))
+	for i, window := range t.windows {
+		stats[i] = window.stats()
+	}
+	return stats
+}
+
+// snrWindows reads the SNR windows from the config. From a config file it's a list, but from an
+// environment variable it's a comma or space separated string
+func snrWindows(configFile *koanf.Koanf) []time.Duration {
+	windows := configFile.Strings("snr.windows")
+	if len(windows) == 0 {
+		windows = strings.FieldsFunc(configFile.String("snr.windows"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	if len(windows) == 0 {
+		return DefaultSNRWindows
+	}
+
+	durations := make([]time.Duration, 0, len(windows))
+	for _, window := range windows {
+		duration, err := time.ParseDuration(window)
+		if err != nil || duration <= 0 {
+			log.Fatalf("Invalid SNR window %q in snr.windows; expected a duration like \"5s\" or \"1m\"", window)
+		}
+		durations = append(durations, duration)
+	}
+	return durations
+}
@@ -0,0 +1,156 @@
+package demod
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type snrReading struct {
+	at  time.Duration
+	snr float64
+}
+
+func TestSNRTrackerWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		readings []snrReading
+		// Stats for the 5s window, then the session
+		want []SNRWindowStats
+	}{
+		{
+			"empty",
+			nil,
+			[]SNRWindowStats{{Window: 5 * time.Second}, {}},
+		},
+		{
+			"within the window",
+			[]snrReading{{0, 1}, {time.Second, 2}, {2 * time.Second, 3}, {3 * time.Second, 4}},
+			[]SNRWindowStats{
+				{Window: 5 * time.Second, Count: 4, Min: 1, Max: 4, Mean: 2.5, StdDev: math.Sqrt(1.25)},
+				{Count: 4, Min: 1, Max: 4, Mean: 2.5, StdDev: math.Sqrt(1.25)},
+			},
+		},
+		{
+			// Readings more than 5s older than the latest drop out of the window, but not the session
+			"expired",
+			[]snrReading{{0, 20}, {time.Second, -4}, {6 * time.Second, 6}, {7 * time.Second, 8}},
+			[]SNRWindowStats{
+				{Window: 5 * time.Second, Count: 2, Min: 6, Max: 8, Mean: 7, StdDev: 1},
+				{Count: 4, Min: -4, Max: 20, Mean: 7.5, StdDev: math.Sqrt(72.75)},
+			},
+		},
+		{
+			"exactly the window old",
+			[]snrReading{{0, 3}, {5 * time.Second, 5}},
+			[]SNRWindowStats{
+				{Window: 5 * time.Second, Count: 2, Min: 3, Max: 5, Mean: 4, StdDev: 1},
+				{Count: 2, Min: 3, Max: 5, Mean: 4, StdDev: 1},
+			},
+		},
+		{
+			"all expired but the latest",
+			[]snrReading{{0, 3}, {time.Second, 3}, {time.Minute, 9}},
+			[]SNRWindowStats{
+				{Window: 5 * time.Second, Count: 1, Min: 9, Max: 9, Mean: 9},
+				{Count: 3, Min: 3, Max: 9, Mean: 5, StdDev: math.Sqrt(8)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newSNRTracker([]time.Duration{5 * time.Second}, 0)
+			for _, r := range tt.readings {
+				tracker.add(testStart.Add(r.at), r.snr)
+			}
+			got := tracker.stats()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d windows, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Window != w.Window || g.Count != w.Count || g.Min != w.Min || g.Max != w.Max ||
+					math.Abs(g.Mean-w.Mean) > 1e-9 || math.Abs(g.StdDev-w.StdDev) > 1e-9 {
+					t.Errorf("window %d: got %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestSNRTrackerPeakHold(t *testing.T) {
+	tests := []struct {
+		name     string
+		readings []snrReading
+		// Reset the peak hold before this reading
+		resetAt  int
+		wantPeak float64
+		wantTime time.Duration
+		wantHeld float64
+	}{
+		{"first reading", []snrReading{{0, 7}}, -1, 7, 0, 7},
+		{"new high", []snrReading{{0, 7}, {time.Second, 9}}, -1, 9, time.Second, 9},
+		// 1 dB/s for 2s
+		{"decaying", []snrReading{{0, 10}, {2 * time.Second, 5}}, -1, 10, 0, 8},
+		{"caught up by a later reading", []snrReading{{0, 10}, {2 * time.Second, 5}, {3 * time.Second, 9}}, -1, 10, 0, 9},
+		// The held value never decays below the latest reading
+		{"decayed away", []snrReading{{0, 10}, {time.Minute, 4}}, -1, 10, 0, 4},
+		{"reset", []snrReading{{0, 10}, {time.Second, 9}, {2 * time.Second, 3}}, 2, 3, 2 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newSNRTracker(nil, 1)
+			for i, r := range tt.readings {
+				if i == tt.resetAt {
+					tracker.resetPeak()
+				}
+				tracker.add(testStart.Add(r.at), r.snr)
+			}
+			peak := tracker.peak
+			if peak.Peak != tt.wantPeak || !peak.Time.Equal(testStart.Add(tt.wantTime)) || math.Abs(peak.Held-tt.wantHeld) > 1e-9 {
+				t.Errorf("got a peak of %.2f at %v, held at %.2f, want %.2f at %v, held at %.2f",
+					peak.Peak, peak.Time.Sub(testStart), peak.Held, tt.wantPeak, tt.wantTime, tt.wantHeld)
+			}
+			// The windows aren't reset with the peak
+			if stats := tracker.stats(); stats[len(stats)-1].Count != len(tt.readings) {
+				t.Errorf("session has %d readings, want %d", stats[len(stats)-1].Count, len(tt.readings))
+			}
+		})
+	}
+}
+
+func TestSNRTrackerSignalLost(t *testing.T) {
+	tracker := newSNRTracker([]time.Duration{5 * time.Second}, 1)
+	tracker.add(testStart, 10)
+	tracker.add(testStart.Add(time.Second), 12)
+	// The signal drops out, and the estimator has nothing to go on for the next 7s
+	for i := 2; i <= 8; i++ {
+		tracker.add(testStart.Add(time.Duration(i)*time.Second), math.NaN())
+	}
+
+	stats := tracker.stats()
+	if window := stats[0]; window.Count != 0 {
+		t.Errorf("the 5s window still has %d readings from before the signal dropped out: %+v", window.Count, window)
+	}
+	if session := stats[1]; session.Count != 2 || session.Max != 12 {
+		t.Errorf("session stats %+v, want the two readings", session)
+	}
+	// 1 dB/s for the 7s since the last reading
+	if peak := tracker.peak; peak.Peak != 12 || math.Abs(peak.Held-5) > 1e-9 {
+		t.Errorf("got a peak of %.2f, held at %.2f, want 12, held at 5", peak.Peak, peak.Held)
+	}
+
+	// A reading after the gap only counts the time since the last no estimate reading
+	tracker.add(testStart.Add(9*time.Second), 2)
+	if held := tracker.peak.Held; math.Abs(held-4) > 1e-9 {
+		t.Errorf("held at %.2f after the signal came back, want 4", held)
+	}
+
+	// Straight after a reset there's nothing to decay
+	tracker.resetPeak()
+	tracker.add(testStart.Add(10*time.Second), math.NaN())
+	tracker.add(testStart.Add(11*time.Second), -3)
+	if peak := tracker.peak; peak.Peak != -3 || peak.Held != -3 {
+		t.Errorf("got a peak of %.2f, held at %.2f after a reset, want the next reading", peak.Peak, peak.Held)
+	}
+}
@@ -0,0 +1,158 @@
+package demod
+
+import "github.com/racerxdl/segdsp/dsp"
+
+// The stages below adapt each of the demodulator's building blocks to the Stage interface
+
+type dcBlockStage struct {
+	blocker *DCBlocker
+}
+
+func (s *dcBlockStage) Name() string { return "dcblock" }
+
+func (s *dcBlockStage) Process(input []complex64) []complex64 {
+	s.blocker.Work(input, input)
+	return input
+}
+
+func (s *dcBlockStage) Stats() []StageStat {
+	offset := s.blocker.GetOffset()
+	return []StageStat{{"DC Offset I", float64(real(offset))}, {"DC Offset Q", float64(imag(offset))}}
+}
+
+type iqCorrectionStage struct {
+	corrector *IQCorrector
+}
+
+func (s *iqCorrectionStage) Name() string { return "iqcorrection" }
+
+func (s *iqCorrectionStage) Process(input []complex64) []complex64 {
+	s.corrector.Work(input, input)
+	return input
+}
+
+func (s *iqCorrectionStage) Stats() []StageStat {
+	amplitude, phase := s.corrector.GetImbalance()
+	return []StageStat{{"Amplitude (dB)", float64(amplitude)}, {"Phase (°)", float64(phase)}}
+}
+
+// spectrumStage is a tap that takes the spectrum plot from wherever it sits in the pipeline
+type spectrumStage struct {
+	d          *Demodulator
+	sampleRate float32
+}
+
+func (s *spectrumStage) Name() string { return "spectrum" }
+
+func (s *spectrumStage) Process(input []complex64) []complex64 {
+	s.d.FFTMutex.Lock()
+	start := !s.d.FFTWorking
+	s.d.FFTWorking = true
+	s.d.FFTMutex.Unlock()
+	if start {
+		// Later stages may work in place, so the FFT gets its own copy
+		go s.d.doFFT(append([]complex64(nil), input...))
+	}
+	return input
+}
+
+func (s *spectrumStage) Stats() []StageStat {
+	return []StageStat{{"Sample Rate", float64(s.sampleRate)}}
+}
+
+type translateStage struct {
+	rotator   *dsp.Rotator
+	frequency float32
+}
+
+func (s *translateStage) Name() string { return "translate" }
+
+func (s *translateStage) Process(input []complex64) []complex64 {
+	s.rotator.WorkInline(input)
+	return input
+}
+
+func (s *translateStage) Stats() []StageStat {
+	return []StageStat{{"Shift (Hz)", float64(-s.frequency)}}
+}
+
+// firStage runs a FIR filter, which is how both the decimator and the RRC filter are built
+type firStage struct {
+	name   string
+	filter *dsp.FirFilter
+	taps   int
+}
+
+func (s *firStage) Name() string { return s.name }
+
+func (s *firStage) Process(input []complex64) []complex64 {
+	return s.filter.Work(input)
+}
+
+func (s *firStage) Stats() []StageStat {
+	return []StageStat{{"Taps", float64(s.taps)}}
+}
+
+type resamplerStage struct {
+	resampler *RationalResampler
+}
+
+func (s *resamplerStage) Name() string { return "resampler" }
+
+func (s *resamplerStage) Process(input []complex64) []complex64 {
+	return s.resampler.Work(input)
+}
+
+func (s *resamplerStage) Stats() []StageStat {
+	return []StageStat{
+		{"Interpolation", float64(s.resampler.GetInterpolation())},
+		{"Decimation", float64(s.resampler.GetDecimation())},
+	}
+}
+
+type agcStage struct {
+	agc *AGC
+}
+
+func (s *agcStage) Name() string { return "agc" }
+
+func (s *agcStage) Process(input []complex64) []complex64 {
+	s.agc.Work(input, input)
+	return input
+}
+
+func (s *agcStage) Stats() []StageStat {
+	return []StageStat{{"Gain", float64(s.agc.GetGain())}}
+}
+
+type costasStage struct {
+	loop       dsp.CostasLoop
+	sampleRate float32
+}
+
+func (s *costasStage) Name() string { return "costas" }
+
+func (s *costasStage) Process(input []complex64) []complex64 {
+	return s.loop.Work(input)
+}
+
+func (s *costasStage) Stats() []StageStat {
+	return []StageStat{
+		{"Frequency (Hz)", float64(s.loop.GetFrequencyHz(s.sampleRate))},
+		{"Error", float64(s.loop.GetAverageError())},
+	}
+}
+
+type clockRecoveryStage struct {
+	recovery TimingRecovery
+}
+
+func (s *clockRecoveryStage) Name() string { return "clockrecovery" }
+
+func (s *clockRecoveryStage) Process(input []complex64) []complex64 {
+	return s.recovery.Work(input)
+}
+
+func (s *clockRecoveryStage) Stats() []StageStat {
+	return []StageStat{{"Mu", float64(s.recovery.GetMu())}, {"Omega", float64(s.recovery.GetOmega())}}
+}
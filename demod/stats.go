@@ -0,0 +1,73 @@
+package demod
+
+import "time"
+
+// DemodulatorStats is an immutable snapshot of the demodulator's stats. The demodulator swaps in a new one
+// after every block, so anything reading it (the TUI, loggers, etc.) always sees a consistent set of
+// values without taking a lock. Don't modify it; it's shared by every reader
+type DemodulatorStats struct {
+	Time time.Time
+	// The Es/N0 in dB from the selected estimator, and the carrier to noise density ratio in dB-Hz. Both
+	// are NaN while the estimator can't make an estimate
+	SNR          float64
+	SNREstimator string
+	CN0          float64
+	// The SNR summarised over each of snr.windows, then over the whole session
+	SNRWindows []SNRWindowStats
+	PeakSNR    PeakHold
+	AGCGain    float32
+	ClockMu    float32
+	ClockOmega float32
+	// Whether the DC blocker and IQ corrector are in the pipeline, and so whether their figures mean anything
+	DCBlock      bool
+	IQCorrection bool
+	DCOffset     complex64
+	IQAmplitude  float32
+	IQPhase      float32
+	Symbols      SymbolStats
+	Stages       []StageStats
+	// The latest spectrum, if do_fft is on
+	FFT []float64
+}
+
+// Stats returns the latest snapshot of the demodulator's stats. It's safe to call from any goroutine
+func (d *Demodulator) Stats() *DemodulatorStats {
+	if stats := d.stats.Load(); stats != nil {
+		return stats
+	}
+	return &DemodulatorStats{}
+}
+
+// publishStats swaps in a new snapshot of the demodulator's stats
+func (d *Demodulator) publishStats(symbols SymbolStats) {
+	stats := DemodulatorStats{
+		Time:         time.Now(),
+		SNR:          d.CurrentSNR,
+		SNREstimator: d.SNR.Name(),
+		CN0:          CN0(d.CurrentSNR, d.conf.xrit.SymbolRate),
+		SNRWindows:   d.snr.stats(),
+		PeakSNR:      d.snr.peak,
+		ClockMu:      d.ClockRecovery.GetMu(),
+		ClockOmega:   d.ClockRecovery.GetOmega(),
+		Symbols:      symbols,
+		Stages:       d.pipelineStats(),
+	}
+	if d.AGC != nil {
+		stats.AGCGain = d.AGC.GetGain()
+	}
+	if d.DCBlocker != nil {
+		stats.DCBlock = true
+		stats.DCOffset = d.DCBlocker.GetOffset()
+	}
+	if d.IQCorrector != nil {
+		stats.IQCorrection = true
+		stats.IQAmplitude, stats.IQPhase = d.IQCorrector.GetImbalance()
+	}
+
+	// The FFT is worked out in the background, and replaced (never modified) when it's done
+	d.FFTMutex.RLock()
+	stats.FFT = d.CurrentFFT
+	d.FFTMutex.RUnlock()
+
+	d.stats.Store(&stats)
+}
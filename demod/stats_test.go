@@ -0,0 +1,96 @@
+package demod
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/jrwynneiii/goestuner/radio"
+	"github.com/knadh/koanf/parsers/hcl"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+)
+
+// testDemodulator builds a demodulator from the example config with some settings overridden, with
+// something draining its symbols
+func testDemodulator(t *testing.T, overrides map[string]any) *Demodulator {
+	conf := koanf.New(".")
+	if err := conf.Load(file.Provider("../config.hcl"), hcl.Parser(true)); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range overrides {
+		if err := conf.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symbols := make(chan []byte, 4)
+	d := New(radio.CF32, float32(conf.Float64("radio.sample_rate")), 4, conf, &symbols)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range symbols {
+		}
+	}()
+	t.Cleanup(func() {
+		close(symbols)
+		<-done
+	})
+	return d
+}
+
+// Run with -race: the TUI reads the stats while the demodulator is working and being reset
+func TestStatsWhileDemodulating(t *testing.T) {
+	// With every stage in, so there's as much as possible to race on
+	d := testDemodulator(t, map[string]any{"dcblock.enabled": true, "iqcorrection.enabled": true})
+	rng := rand.New(rand.NewPCG(43, 1))
+	samples := make([]complex64, 66560)
+	for i := range samples {
+		samples[i] = complex(float32(rng.NormFloat64()), float32(rng.NormFloat64()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			d.demodBlock(ctx, samples)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			d.Reset()
+			d.ResetPeakSNR()
+		}
+	}()
+
+	readers := make(chan struct{})
+	go func() {
+		defer close(readers)
+		for ctx.Err() == nil {
+			stats := d.Stats()
+			if stats.DCBlock {
+				_ = stats.DCOffset
+			}
+			for _, window := range stats.SNRWindows {
+				_ = window.Mean
+			}
+			for _, stage := range stats.Stages {
+				_ = len(stage.Stats)
+			}
+			_ = len(stats.FFT)
+		}
+	}()
+
+	wg.Wait()
+	cancel()
+	<-readers
+
+	stats := d.Stats()
+	if !stats.DCBlock || !stats.IQCorrection {
+		t.Errorf("the DC blocker and IQ corrector aren't in the stats: %v, %v", stats.DCBlock, stats.IQCorrection)
+	}
+}
@@ -0,0 +1,67 @@
+package demod
+
+import "math"
+
+// SymbolHistogramBins is how many buckets the soft symbol histogram is split into
+const SymbolHistogramBins = 16
+
+// SymbolStats describes the soft symbols of the last block handed to the decoder, to help tune the
+// symbol scaling for the Viterbi decoder
+type SymbolStats struct {
+	// Scale is the multiplier that turns a symbol's amplitude into a soft symbol offset from 128
+	Scale float32
+	// MeanMagnitude is the mean absolute amplitude of the symbols, before scaling
+	MeanMagnitude float64
+	// MeanSoftMagnitude is the mean distance of the soft symbols from 128, out of 128
+	MeanSoftMagnitude float64
+	// ClipRate is the percentage of symbols that were clipped to 0 or 255
+	ClipRate float64
+	// Histogram counts the soft symbols in SymbolHistogramBins evenly sized buckets from 0 to 255
+	Histogram [SymbolHistogramBins]int
+}
+
+// symbolScale works out the soft symbol scale from the AGC reference: with a scale of 1, a symbol at
+// the reference level lands at full scale (1 or 255)
+func symbolScale(scale float32, reference float32) float32 {
+	if scale == 0 {
+		scale = 1
+	}
+	if reference == 0 {
+		reference = 1
+	}
+	return 127 * scale / reference
+}
+
+// softSymbols turns the in-phase part of each symbol into a soft symbol centred on 128. A positive
+// symbol is a 0 bit (below 128) and a negative symbol a 1 bit (above 128), matching the polarity the
+// sync words are correlated against
+func softSymbols(in []complex64, scale float32, stats *SymbolStats) []byte {
+	symbols := make([]byte, len(in))
+	var magnitude, softMagnitude float64
+	clipped := 0
+	*stats = SymbolStats{Scale: scale}
+
+	for i, val := range in {
+		sym := 128 - real(val)*scale
+		if sym <= 0 {
+			sym = 0
+			clipped++
+		} else if sym >= 255 {
+			sym = 255
+			clipped++
+		}
+		symbols[i] = byte(math.Round(float64(sym)))
+
+		magnitude += math.Abs(float64(real(val)))
+		softMagnitude += math.Abs(float64(symbols[i]) - 128)
+		stats.Histogram[int(symbols[i])*SymbolHistogramBins/256]++
+	}
+
+	if len(in) > 0 {
+		n := float64(len(in))
+		stats.MeanMagnitude = magnitude / n
+		stats.MeanSoftMagnitude = softMagnitude / n
+		stats.ClipRate = 100 * float64(clipped) / n
+	}
+	return symbols
+}
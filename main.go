@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/alecthomas/kong"
 	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/antenna"
 	"github.com/jrwynneiii/goestuner/config"
 	"github.com/jrwynneiii/goestuner/datalink"
 	"github.com/jrwynneiii/goestuner/demod"
+	"github.com/jrwynneiii/goestuner/products"
 	"github.com/jrwynneiii/goestuner/radio"
+	"github.com/jrwynneiii/goestuner/transport"
 	"github.com/jrwynneiii/goestuner/tui"
 
 	"github.com/knadh/koanf/parsers/hcl"
@@ -28,10 +35,23 @@ var cli struct {
 	} `cmd:"" help:"List the available radios and SoapySDR configuration"`
 	Tune struct {
 	} `cmd:"" help:"Starts the TUI and connects to the SDR"`
+	Point struct {
+		Lat  *float64 `help:"Latitude of the dish in degrees, north positive (default: station.latitude)"`
+		Lon  *float64 `help:"Longitude of the dish in degrees, east positive (default: station.longitude)"`
+		Alt  *float64 `help:"Altitude of the dish in metres (default: station.altitude)"`
+		Goto string   `help:"Turn the rotator (see the rotator block) to point at the named satellite"`
+	} `cmd:"" help:"Works out where to point the dish at each satellite"`
 }
 
 var configFile = koanf.New(".")
 
+// Number of VCDUs and LRIT files that may be queued up between the datalink layer and its consumers
+const frameBufferSize = 1024
+
+// Number of sample blocks, and batches of symbols, that may be queued up between the radio, demodulator
+// and decoder
+const blockBufferSize = 16
+
 func getConfigPath() string {
 	paths := []string{"/etc/goestuner/config.hcl", "~/.config/goestuner/config.hcl", "./config.hcl"}
 	for _, path := range paths {
@@ -44,6 +64,86 @@ func getConfigPath() string {
 	return ""
 }
 
+// startStage runs a stage's Start in its own goroutine, returning a channel that's closed once it returns
+func startStage(start func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		start()
+	}()
+	return done
+}
+
+// stationLocation reads the station block, returning false if it isn't set
+func stationLocation() (antenna.Station, bool) {
+	stationDef := config.StationConf{
+		Latitude:  configFile.Float64("station.latitude"),
+		Longitude: configFile.Float64("station.longitude"),
+		Altitude:  configFile.Float64("station.altitude"),
+	}
+	log.Debugf("Found station definition: %##v", stationDef)
+	station := antenna.Station{Latitude: stationDef.Latitude, Longitude: stationDef.Longitude, Altitude: stationDef.Altitude}
+	return station, configFile.Exists("station.latitude") && configFile.Exists("station.longitude")
+}
+
+// rotatorConfig reads the rotator block
+func rotatorConfig() config.RotatorConf {
+	rotatorDef := config.RotatorConf{
+		Enabled:      configFile.Bool("rotator.enabled"),
+		Address:      configFile.String("rotator.address"),
+		Step:         configFile.Float64("rotator.step"),
+		PollMs:       configFile.Int("rotator.poll_ms"),
+		MinAzimuth:   configFile.Float64("rotator.min_azimuth"),
+		MaxAzimuth:   configFile.Float64("rotator.max_azimuth"),
+		MinElevation: configFile.Float64("rotator.min_elevation"),
+		MaxElevation: configFile.Float64("rotator.max_elevation"),
+	}
+	log.Debugf("Found rotator definition: %##v", rotatorDef)
+	return rotatorDef
+}
+
+// gotoSatellite turns the rotator to point at the named satellite
+func gotoSatellite(station antenna.Station, satellites []antenna.Satellite, name string) error {
+	for _, angle := range antenna.LookAngles(station, satellites) {
+		if !strings.EqualFold(angle.Satellite.Name, name) {
+			continue
+		}
+		if !angle.Visible() {
+			return fmt.Errorf("%s is below the horizon", angle.Satellite.Name)
+		}
+
+		rotatorDef := rotatorConfig()
+		if rotatorDef.Address == "" {
+			return errors.New("No rotator; set rotator.address")
+		}
+		rotator := antenna.NewRotator(rotatorDef)
+		defer rotator.Close()
+		if err := rotator.SetPosition(angle.Azimuth, angle.Elevation); err != nil {
+			return fmt.Errorf("Could not turn the rotator: %w", err)
+		}
+		fmt.Printf("\nTurning the rotator to %s: azimuth %.1f°, elevation %.1f°\n", angle.Satellite.Name, angle.Azimuth, angle.Elevation)
+		if position, err := rotator.Position(); err == nil {
+			fmt.Printf("The rotator is currently at azimuth %.1f°, elevation %.1f°\n", position.Azimuth, position.Elevation)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown satellite %q; it needs to be in the satellites block", name)
+}
+
+// printLookAngles prints where to point the dish at each satellite
+func printLookAngles(station antenna.Station, satellites []antenna.Satellite) {
+	fmt.Printf("Station: %.4f°, %.4f°, %.0f m\n\n", station.Latitude, station.Longitude, station.Altitude)
+	fmt.Printf("%-12s %10s %10s %10s %10s %10s\n", "Satellite", "Longitude", "Azimuth", "Elevation", "Skew", "Range (km)")
+	for _, angle := range antenna.LookAngles(station, satellites) {
+		note := ""
+		if !angle.Visible() {
+			note = "  below the horizon"
+		}
+		fmt.Printf("%-12s %9.1f° %9.1f° %9.1f° %+9.1f° %10.0f%s\n", angle.Satellite.Name, angle.Satellite.Longitude, angle.Azimuth, angle.Elevation, angle.Skew, angle.Range, note)
+	}
+	fmt.Println("\nAzimuth is from true north, not magnetic north. Skew is how far to rotate the LNB, clockwise as seen from behind the dish")
+}
+
 func main() {
 	log.Info("Starting GOESWatcher")
 	flags := kong.Parse(&cli)
@@ -79,6 +179,29 @@ func main() {
 	case "probe":
 		radio.LogAllSoapySDRDevices()
 
+	case "point":
+		// The flags override the station block
+		station, ok := stationLocation()
+		if cli.Point.Lat != nil {
+			station.Latitude = *cli.Point.Lat
+		}
+		if cli.Point.Lon != nil {
+			station.Longitude = *cli.Point.Lon
+		}
+		if cli.Point.Alt != nil {
+			station.Altitude = *cli.Point.Alt
+		}
+		if !ok && (cli.Point.Lat == nil || cli.Point.Lon == nil) {
+			log.Fatal("No station location; pass --lat and --lon, or set them in the station block")
+		}
+		satellites := antenna.SatellitesFromMap(configFile.Float64Map("satellites"))
+		printLookAngles(station, satellites)
+		if cli.Point.Goto != "" {
+			if err := gotoSatellite(station, satellites, cli.Point.Goto); err != nil {
+				log.Fatal(err)
+			}
+		}
+
 	case "tune":
 		rname := configFile.String("radio.driver")
 
@@ -98,6 +221,8 @@ func main() {
 			VitWarnPct:      configFile.Float64("tui.vit_threshold_warn_pct"),
 			VitCritPct:      configFile.Float64("tui.vit_threshold_crit_pct"),
 			EnableLogOutput: configFile.Bool("tui.enable_log_output"),
+			HistoryMinutes:  configFile.Int("tui.history_minutes"),
+			PointingMetric:  configFile.String("tui.pointing_metric"),
 		}
 		xritChunkSize := uint(configFile.Int("xrit.chunk_size"))
 		xritDoFFT := configFile.Bool("xrit.do_fft")
@@ -106,19 +231,67 @@ func main() {
 		log.Debug("Starting init of SDR")
 		switch rdef.SampleType {
 		case "complex64":
-			decoder := datalink.New(xritChunkSize, configFile)
-			demodulator := demod.New(radio.CF32, float32(rdef.SampleRate), xritChunkSize, configFile, &decoder.SymbolsInput)
+			// q in the TUI, SIGINT and SIGTERM all shut us down the same way
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// The frame consumers stop once the decoder closes their inputs
+			var consumers sync.WaitGroup
+			var framesOutputs []*datalink.FrameOutput
+			var processor *products.Processor
+			if configFile.Bool("products.enabled") {
+				processor = products.New(frameBufferSize, configFile)
+				demuxer := transport.New(frameBufferSize, &processor.FileInput)
+				framesOutputs = append(framesOutputs, &datalink.FrameOutput{Name: "transport", Frames: &demuxer.FrameInput})
+
+				consumers.Add(2)
+				go func() { defer consumers.Done(); processor.Start() }()
+				go func() { defer consumers.Done(); demuxer.Start() }()
+			}
+
+			publisher := datalink.NewPublisher(frameBufferSize, configFile)
+			if publisher.Enabled() {
+				framesOutputs = append(framesOutputs, &datalink.FrameOutput{Name: "framedump", Frames: &publisher.FrameInput, Lossy: true})
+				consumers.Add(1)
+				go func() { defer consumers.Done(); publisher.Start() }()
+			}
+
+			decoder := datalink.New(blockBufferSize, configFile, framesOutputs)
+			demodulator := demod.New(radio.CF32, float32(rdef.SampleRate), blockBufferSize, configFile, &decoder.SymbolsInput)
 			r := radio.New[complex64](rdef, rname, radio.CF32, xritChunkSize, &demodulator.SampleInput)
 			r.Connect()
 
-			go r.Start()
-			go demodulator.Start()
-			go decoder.Start()
-			defer demodulator.Close()
-			defer decoder.Close()
-			defer r.Destroy()
+			radioDone := startStage(func() { r.Start(ctx) })
+			demodDone := startStage(func() { demodulator.Start(ctx) })
+			decoderDone := startStage(func() { decoder.Start(ctx) })
+
+			var lookAngles []antenna.LookAngle
+			if station, ok := stationLocation(); ok {
+				lookAngles = antenna.LookAngles(station, antenna.SatellitesFromMap(configFile.Float64Map("satellites")))
+			}
+
+			var rotator *antenna.Rotator
+			if rotatorDef := rotatorConfig(); rotatorDef.Enabled {
+				rotator = antenna.NewRotator(rotatorDef)
+				go rotator.Track(ctx)
+			}
+
+			tui.StartUI(ctx, decoder, demodulator, r, processor, xritDoFFT, tuiDef, lookAngles, rotator)
 
-			tui.StartUI(decoder, demodulator, r, xritDoFFT, tuiDef)
+			// Stop front to back, so nothing is left sending on a channel nobody reads. The samples and
+			// symbols still queued are thrown away, but every frame the decoder got out is written out
+			log.Info("Shutting down...")
+			stop()
+			<-radioDone
+			<-demodDone
+			<-decoderDone
+			consumers.Wait()
+			publisher.Close()
+			r.Destroy()
+			if rotator != nil {
+				rotator.Close()
+			}
+			log.Info("Stopped")
 		default:
 			log.Fatalf("Unsupported sample_type defined for radio %s\n Supported sample types are: [CF32]", rname)
 		}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/datalink"
+	"github.com/jrwynneiii/goestuner/demod"
+	"github.com/jrwynneiii/goestuner/radio"
+)
+
+// Flush throws away everything in flight between the SDR and the decoder and starts the demodulator and
+// decoder over, e.g. after retuning or repointing the dish. The radio, demodulator and decoder keep
+// running throughout; the SDR is paused while the queues are emptied, then reconnected
+func Flush(r *radio.Radio[complex64], demodulator *demod.Demodulator, decoder *datalink.Decoder) {
+	log.Debug("Pausing SDR")
+	r.Pause()
+
+	log.Debug("Flushing physical layer")
+	drain(demodulator.SampleInput)
+	demodulator.Reset()
+
+	log.Debug("Flushing datalink layer")
+	drain(*demodulator.SymbolsOutput)
+	decoder.Reset()
+	r.OutputStats.Reset()
+
+	log.Debug("Reconnecting to SDR...")
+	r.Connect()
+	log.Debug("Flushed!")
+}
+
+// drain discards whatever is queued on ch without waiting for more
+func drain[T any](ch chan T) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package products
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/transport"
+)
+
+// VCID NOAA sends operator messages on
+const adminVCID = 0
+
+// Admin messages are saved as <timestamp>_<name>.txt so they sort, and can be reloaded, by time
+const adminTimeFormat = "20060102T150405Z"
+
+type AdminMessage struct {
+	Time time.Time
+	Name string
+	Text string
+}
+
+// fileName is the name the message is saved under. NOAA sends each message many times over, and every
+// copy has the same one
+func (m AdminMessage) fileName() string {
+	return m.Time.Format(adminTimeFormat) + "_" + m.Name + ".txt"
+}
+
+type AdminHandler struct {
+	OutputDir string
+	mutex     sync.RWMutex
+	messages  []AdminMessage
+}
+
+func NewAdminHandler(outputDir string) *AdminHandler {
+	h := AdminHandler{
+		OutputDir: outputDir,
+	}
+	h.load()
+	return &h
+}
+
+// load reads back the messages saved by previous runs, so they show up even if they were received
+// while the TUI wasn't open
+func (h *AdminHandler) load() {
+	entries, err := os.ReadDir(h.OutputDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		stamp, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".txt"), "_")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		t, err := time.Parse(adminTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		text, err := os.ReadFile(filepath.Join(h.OutputDir, entry.Name()))
+		if err != nil {
+			log.Warnf("[Admin] Could not read saved message %s: %v", entry.Name(), err)
+			continue
+		}
+		h.messages = append(h.messages, AdminMessage{Time: t, Name: name, Text: string(text)})
+	}
+
+	sort.SliceStable(h.messages, func(i, j int) bool {
+		return h.messages[i].Time.Before(h.messages[j].Time)
+	})
+}
+
+func (h *AdminHandler) Handle(f *transport.File) {
+	if f.VCID != adminVCID || f.Headers.Primary.FileType != transport.FileTypeText {
+		return
+	}
+
+	msg := AdminMessage{
+		Time: fileTime(f),
+		Name: sanitizeName(strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))),
+		Text: strings.ReplaceAll(string(f.Data), "\r", ""),
+	}
+
+	// Like load, keep one of each message, whichever copy arrived first
+	h.mutex.Lock()
+	for _, held := range h.messages {
+		if held.fileName() == msg.fileName() {
+			h.mutex.Unlock()
+			log.Debugf("[Admin] Already have admin message %s", msg.Name)
+			return
+		}
+	}
+	h.messages = append(h.messages, msg)
+	h.mutex.Unlock()
+
+	path := filepath.Join(h.OutputDir, msg.fileName())
+	if err := writeFile(path, []byte(msg.Text)); err != nil {
+		log.Errorf("[Admin] Could not save admin message %s: %v", path, err)
+	}
+	log.Warnf("[Admin] New admin message: %s", msg.Name)
+}
+
+// Messages returns a copy of every admin message received, oldest first
+func (h *AdminHandler) Messages() []AdminMessage {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return append([]AdminMessage{}, h.messages...)
+}
@@ -0,0 +1,56 @@
+package products
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jrwynneiii/goestuner/transport"
+)
+
+func adminFile(name string, stamp time.Time, text string) *transport.File {
+	return &transport.File{
+		VCID: adminVCID,
+		Headers: &transport.Headers{
+			Primary:    transport.PrimaryHeader{FileType: transport.FileTypeText},
+			Annotation: name,
+			TimeStamp:  stamp,
+		},
+		Data: []byte(text),
+	}
+}
+
+func TestAdminRebroadcasts(t *testing.T) {
+	dir := t.TempDir()
+	h := NewAdminHandler(dir)
+	sent := time.Date(2026, 10, 18, 14, 30, 5, 250e6, time.UTC)
+
+	// NOAA sends the same message over and over
+	for i := 0; i < 5; i++ {
+		h.Handle(adminFile("ADMIN_MSG.txt", sent, "GOES-East HRIT outage\r\n"))
+	}
+	h.Handle(adminFile("ADMIN_MSG.txt", sent.Add(time.Hour), "GOES-East HRIT restored\r\n"))
+	h.Handle(adminFile("OTHER_MSG.txt", sent, "Something else\r\n"))
+
+	messages := h.Messages()
+	if len(messages) != 3 {
+		t.Fatalf("holding %d messages, want 3: %+v", len(messages), messages)
+	}
+	if messages[0].Name != "ADMIN_MSG" || messages[0].Text != "GOES-East HRIT outage\n" {
+		t.Errorf("first message %+v", messages[0])
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("saved %d files, want 3", len(entries))
+	}
+
+	// After a restart, the saved copies count too
+	h = NewAdminHandler(dir)
+	h.Handle(adminFile("ADMIN_MSG.txt", sent, "GOES-East HRIT outage\r\n"))
+	if messages := h.Messages(); len(messages) != 3 {
+		t.Errorf("holding %d messages after a restart, want 3: %+v", len(messages), messages)
+	}
+}
@@ -0,0 +1,263 @@
+package products
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/transport"
+)
+
+// VCID that carries DCS files in the block based "new" format. The other DCS channels use the
+// LRGS/DOMSAT style ASCII headers
+const dcsNewFormatVCID = 32
+
+// Sizes of the fixed parts of the two DCS formats
+const (
+	dcsFileHeaderSize   = 64
+	dcsBlockHeaderSize  = 3
+	dcsDCPHeaderSize    = 38
+	dcsBlockCRCSize     = 2
+	dcsLegacyHeaderSize = 37
+)
+
+// Block ID of a DCP message block in the new format
+const dcsBlockDCPMessage = 1
+
+// DCSMessage is a single DCP message, written out as one line of JSON
+type DCSMessage struct {
+	File            string     `json:"file"`
+	Format          string     `json:"format"`
+	PlatformAddress string     `json:"platform_address"`
+	CarrierStart    time.Time  `json:"carrier_start"`
+	MessageEnd      *time.Time `json:"message_end,omitempty"`
+	BaudRate        int        `json:"baud_rate,omitempty"`
+	SignalStrength  float64    `json:"signal_strength_dbm"`
+	FrequencyOffset float64    `json:"frequency_offset_hz"`
+	Channel         int        `json:"channel"`
+	Spacecraft      string     `json:"spacecraft,omitempty"`
+	ParityError     bool       `json:"parity_error"`
+	FailureCode     string     `json:"failure_code,omitempty"`
+	Data            string     `json:"data"`
+}
+
+type DCSHandler struct {
+	OutputDir string
+	Platforms map[string]bool
+}
+
+func NewDCSHandler(outputDir string, platforms []string) *DCSHandler {
+	h := DCSHandler{
+		OutputDir: outputDir,
+	}
+	for _, p := range platforms {
+		if p = strings.TrimSpace(p); p != "" {
+			if h.Platforms == nil {
+				h.Platforms = make(map[string]bool)
+			}
+			h.Platforms[strings.ToUpper(p)] = true
+		}
+	}
+	return &h
+}
+
+func (h *DCSHandler) Handle(f *transport.File) {
+	if f.Headers.Primary.FileType != transport.FileTypeDCS {
+		return
+	}
+
+	var messages []DCSMessage
+	var err error
+	if f.VCID == dcsNewFormatVCID {
+		messages, err = parseDCSBlocks(f.Name(), f.Data)
+	} else {
+		messages, err = parseDCSLegacy(f.Name(), f.Data)
+	}
+	if err != nil {
+		log.Warnf("[DCS] Could not parse %s: %v", f.Name(), err)
+		if len(messages) == 0 {
+			return
+		}
+	}
+
+	if err := h.write(messages); err != nil {
+		log.Errorf("[DCS] Could not write messages from %s: %v", f.Name(), err)
+		return
+	}
+	log.Debugf("[DCS] Decoded %d messages from %s", len(messages), f.Name())
+}
+
+// write appends the messages from the platforms we care about to the JSON lines output
+func (h *DCSHandler) write(messages []DCSMessage) error {
+	if err := os.MkdirAll(h.OutputDir, 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(filepath.Join(h.OutputDir, "messages.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	for _, msg := range messages {
+		if h.Platforms != nil && !h.Platforms[msg.PlatformAddress] {
+			continue
+		}
+		if err := enc.Encode(msg); err != nil {
+			return err
+		}
+		if h.Platforms != nil {
+			log.Infof("[DCS] Message from platform %s on channel %d: %q", msg.PlatformAddress, msg.Channel, msg.Data)
+		}
+	}
+	return nil
+}
+
+// parseDCSBlocks parses the binary "new" DCS file format: a 64 byte ASCII file header followed by
+// little endian blocks, each holding a single DCP message
+func parseDCSBlocks(name string, data []byte) ([]DCSMessage, error) {
+	if len(data) < dcsFileHeaderSize {
+		return nil, fmt.Errorf("File too short for a DCS file header: %d bytes", len(data))
+	}
+
+	var messages []DCSMessage
+	pos := dcsFileHeaderSize
+	for pos+dcsBlockHeaderSize <= len(data) {
+		blockID := data[pos]
+		length := int(binary.LittleEndian.Uint16(data[pos+1 : pos+3]))
+		if length < dcsBlockHeaderSize || pos+length > len(data) {
+			return messages, fmt.Errorf("Malformed block at offset %d", pos)
+		}
+		block := data[pos : pos+length]
+		pos += length
+
+		if blockID != dcsBlockDCPMessage {
+			continue
+		}
+		if len(block) < dcsDCPHeaderSize+dcsBlockCRCSize {
+			return messages, fmt.Errorf("DCP message block too short: %d bytes", len(block))
+		}
+
+		flags := block[6]
+		msg := DCSMessage{
+			File:            name,
+			Format:          "block",
+			PlatformAddress: fmt.Sprintf("%08X", binary.LittleEndian.Uint32(block[8:12])),
+			BaudRate:        dcsBaudRates[flags&0x07],
+			ParityError:     flags&0x10 != 0,
+			SignalStrength:  float64(binary.LittleEndian.Uint16(block[26:28])&0x03FF) / 10.0,
+			// 14 bit two's complement, in tenths of a Hz
+			FrequencyOffset: float64(int16(binary.LittleEndian.Uint16(block[28:30])<<2)>>2) / 10.0,
+			Channel:         int(binary.LittleEndian.Uint16(block[33:35]) & 0x03FF),
+			Spacecraft:      dcsSpacecraft[int(binary.LittleEndian.Uint16(block[33:35])>>12)],
+			Data:            string(block[dcsDCPHeaderSize : len(block)-dcsBlockCRCSize]),
+		}
+
+		start, err := parseBCDTime(block[12:19])
+		if err != nil {
+			return messages, fmt.Errorf("Platform %s: bad carrier start time: %v", msg.PlatformAddress, err)
+		}
+		msg.CarrierStart = start
+		if end, err := parseBCDTime(block[19:26]); err == nil {
+			msg.MessageEnd = &end
+		}
+
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+var dcsBaudRates = map[byte]int{
+	1: 100,
+	2: 300,
+	3: 1200,
+}
+
+var dcsSpacecraft = map[int]string{
+	1: "E",
+	2: "W",
+	3: "C",
+}
+
+// parseBCDTime decodes the 7 byte, little endian BCD "YYDDDHHMMSSZZZ" timestamps used by the block format
+func parseBCDTime(b []byte) (time.Time, error) {
+	if len(b) != 7 {
+		return time.Time{}, fmt.Errorf("BCD timestamp is %d bytes, not 7", len(b))
+	}
+	var digits [14]byte
+	for i, v := range b {
+		if v>>4 > 9 || v&0x0F > 9 {
+			return time.Time{}, fmt.Errorf("Invalid BCD byte %#02x", v)
+		}
+		// The last byte holds the first two digits
+		digits[12-2*i] = '0' + v>>4
+		digits[13-2*i] = '0' + v&0x0F
+	}
+	return parseDCSTime(string(digits[:11]), string(digits[11:]))
+}
+
+// parseDCSTime parses a "YYDDDHHMMSS" timestamp, plus optional milliseconds
+func parseDCSTime(stamp string, millis string) (time.Time, error) {
+	t, err := time.Parse("06002150405", stamp)
+	if err != nil {
+		return t, err
+	}
+	if millis != "" {
+		ms, err := strconv.Atoi(millis)
+		if err != nil {
+			return t, err
+		}
+		t = t.Add(time.Duration(ms) * time.Millisecond)
+	}
+	return t, nil
+}
+
+// parseDCSLegacy parses the older DCS format, which is a run of DCP messages each preceded by the
+// 37 character LRGS/DOMSAT header
+func parseDCSLegacy(name string, data []byte) ([]DCSMessage, error) {
+	var messages []DCSMessage
+	pos := 0
+	for pos+dcsLegacyHeaderSize <= len(data) {
+		hdr := string(data[pos : pos+dcsLegacyHeaderSize])
+		length, err := strconv.Atoi(hdr[32:37])
+		if err != nil || pos+dcsLegacyHeaderSize+length > len(data) {
+			return messages, fmt.Errorf("Malformed message header at offset %d: %q", pos, hdr)
+		}
+
+		msg := DCSMessage{
+			File:            name,
+			Format:          "legacy",
+			PlatformAddress: strings.ToUpper(hdr[0:8]),
+			FailureCode:     hdr[19:20],
+			ParityError:     hdr[19] == '?',
+			Spacecraft:      hdr[29:30],
+			Data:            string(data[pos+dcsLegacyHeaderSize : pos+dcsLegacyHeaderSize+length]),
+		}
+		if msg.CarrierStart, err = parseDCSTime(hdr[8:19], ""); err != nil {
+			return messages, fmt.Errorf("Platform %s: bad timestamp: %v", msg.PlatformAddress, err)
+		}
+		if ss, err := strconv.Atoi(hdr[20:22]); err == nil {
+			msg.SignalStrength = float64(ss)
+		}
+		// Frequency offset is a sign and a count of 50 Hz steps
+		if steps, err := strconv.Atoi(strings.TrimSpace(hdr[23:24])); err == nil {
+			msg.FrequencyOffset = float64(steps) * 50
+			if hdr[22] == '-' {
+				msg.FrequencyOffset = -msg.FrequencyOffset
+			}
+		}
+		if ch, err := strconv.Atoi(strings.TrimSpace(hdr[26:29])); err == nil {
+			msg.Channel = ch
+		}
+
+		messages = append(messages, msg)
+		pos += dcsLegacyHeaderSize + length
+	}
+	return messages, nil
+}
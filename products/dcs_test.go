@@ -0,0 +1,213 @@
+package products
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dcpBlock builds a DCP message block in the block format
+func dcpBlock(flags byte, address uint32, start, end []byte, signal, offset, channel uint16, data string) []byte {
+	block := make([]byte, dcsDCPHeaderSize, dcsDCPHeaderSize+len(data)+dcsBlockCRCSize)
+	block[0] = dcsBlockDCPMessage
+	binary.LittleEndian.PutUint16(block[1:3], uint16(dcsDCPHeaderSize+len(data)+dcsBlockCRCSize))
+	block[6] = flags
+	binary.LittleEndian.PutUint32(block[8:12], address)
+	copy(block[12:19], start)
+	copy(block[19:26], end)
+	binary.LittleEndian.PutUint16(block[26:28], signal)
+	binary.LittleEndian.PutUint16(block[28:30], offset)
+	binary.LittleEndian.PutUint16(block[33:35], channel)
+	block = append(block, data...)
+	// parseDCSBlocks doesn't check the CRC
+	return append(block, 0, 0)
+}
+
+// dcsFileHeader is the 64 byte ASCII header at the start of each block format file
+func dcsFileHeader() []byte {
+	return []byte(strings.Repeat(" ", dcsFileHeaderSize))
+}
+
+func TestParseBCDTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    time.Time
+		wantErr bool
+	}{
+		{"with milliseconds", []byte{0x45, 0x23, 0x01, 0x53, 0x31, 0x01, 0x24}, time.Date(2024, 1, 13, 15, 30, 12, 345e6, time.UTC), false},
+		{"end of a leap year", []byte{0x99, 0x99, 0x95, 0x35, 0x62, 0x36, 0x24}, time.Date(2024, 12, 31, 23, 59, 59, 999e6, time.UTC), false},
+		{"too short", []byte{0x45, 0x23, 0x01, 0x53, 0x31, 0x01}, time.Time{}, true},
+		{"too long", []byte{0x45, 0x23, 0x01, 0x53, 0x31, 0x01, 0x24, 0x00}, time.Time{}, true},
+		{"not BCD", []byte{0x45, 0x23, 0x01, 0x5A, 0x31, 0x01, 0x24}, time.Time{}, true},
+		{"not a time", []byte{0x00, 0x00, 0x00, 0x99, 0x31, 0x01, 0x24}, time.Time{}, true},
+		{"nil", nil, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBCDTime(tt.b)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseBCDTime() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseBCDTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDCSBlocks(t *testing.T) {
+	start := []byte{0x45, 0x23, 0x01, 0x53, 0x31, 0x01, 0x24}
+	end := []byte{0x80, 0x54, 0x01, 0x53, 0x31, 0x01, 0x24}
+
+	data := dcsFileHeader()
+	// 300 baud with a parity error, -12.5 Hz with the two bits above the offset set, and channel 123
+	// on GOES West with the bits between the channel and the spacecraft set
+	data = append(data, dcpBlock(0x12, 0xCE4F2D5C, start, end, 0xFC00|437, 0xBF83, 0x2C00|123, "18.2 3.1 +0.4")...)
+	// Blocks that aren't DCP messages are skipped
+	data = append(data, 2, 5, 0, 0xAA, 0xBB)
+	// 1200 baud, +87.3 Hz on channel 301 on GOES East, with a message end time that can't be read
+	data = append(data, dcpBlock(0x03, 0x1234ABCD, start, make([]byte, 7), 321, 0xC369, 0x1000|301, "B1@@Gt")...)
+	// The most negative offset there is
+	data = append(data, dcpBlock(0x01, 0x00000001, start, end, 0, 0x2000, 0x3000|1, "")...)
+
+	messages, err := parseDCSBlocks("test.dcs", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("parsed %d messages, want 3", len(messages))
+	}
+
+	carrierStart := time.Date(2024, 1, 13, 15, 30, 12, 345e6, time.UTC)
+	messageEnd := time.Date(2024, 1, 13, 15, 30, 15, 480e6, time.UTC)
+	tests := []struct {
+		address    string
+		baud       int
+		parity     bool
+		signal     float64
+		offset     float64
+		channel    int
+		spacecraft string
+		end        *time.Time
+		data       string
+	}{
+		{"CE4F2D5C", 300, true, 43.7, -12.5, 123, "W", &messageEnd, "18.2 3.1 +0.4"},
+		{"1234ABCD", 1200, false, 32.1, 87.3, 301, "E", nil, "B1@@Gt"},
+		{"00000001", 100, false, 0, -819.2, 1, "C", &messageEnd, ""},
+	}
+	for i, tt := range tests {
+		msg := messages[i]
+		if msg.File != "test.dcs" || msg.Format != "block" || msg.PlatformAddress != tt.address {
+			t.Errorf("message %d: file %q, format %q, platform %q", i, msg.File, msg.Format, msg.PlatformAddress)
+		}
+		if msg.BaudRate != tt.baud || msg.ParityError != tt.parity {
+			t.Errorf("message %d: %d baud, parity error %v, want %d, %v", i, msg.BaudRate, msg.ParityError, tt.baud, tt.parity)
+		}
+		if msg.SignalStrength != tt.signal || msg.FrequencyOffset != tt.offset {
+			t.Errorf("message %d: %.1f dBm, %.1f Hz, want %.1f, %.1f", i, msg.SignalStrength, msg.FrequencyOffset, tt.signal, tt.offset)
+		}
+		if msg.Channel != tt.channel || msg.Spacecraft != tt.spacecraft {
+			t.Errorf("message %d: channel %d%s, want %d%s", i, msg.Channel, msg.Spacecraft, tt.channel, tt.spacecraft)
+		}
+		if !msg.CarrierStart.Equal(carrierStart) {
+			t.Errorf("message %d: carrier start %v, want %v", i, msg.CarrierStart, carrierStart)
+		}
+		if (msg.MessageEnd == nil) != (tt.end == nil) || (tt.end != nil && !msg.MessageEnd.Equal(*tt.end)) {
+			t.Errorf("message %d: message end %v, want %v", i, msg.MessageEnd, tt.end)
+		}
+		if msg.Data != tt.data {
+			t.Errorf("message %d: data %q, want %q", i, msg.Data, tt.data)
+		}
+	}
+}
+
+func TestParseDCSBlocksMalformed(t *testing.T) {
+	start := []byte{0x45, 0x23, 0x01, 0x53, 0x31, 0x01, 0x24}
+	good := dcpBlock(0x01, 0xCE4F2D5C, start, start, 0, 0, 1, "OK")
+
+	tests := []struct {
+		name string
+		data []byte
+		// Messages parsed before the error
+		want int
+	}{
+		{"no file header", []byte("too short"), 0},
+		{"block runs off the end", append(append(dcsFileHeader(), good...), 1, 0xFF, 0), 1},
+		{"block shorter than its header", append(dcsFileHeader(), 1, 2, 0), 0},
+		{"DCP block too short", append(append(dcsFileHeader(), good...), 1, 10, 0, 0, 0, 0, 0, 0, 0, 0), 1},
+		{"bad carrier start", append(dcsFileHeader(), dcpBlock(0x01, 1, make([]byte, 7), start, 0, 0, 1, "")...), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := parseDCSBlocks("test.dcs", tt.data)
+			if err == nil {
+				t.Error("parseDCSBlocks() didn't return an error")
+			}
+			if len(messages) != tt.want {
+				t.Errorf("parsed %d messages before the error, want %d", len(messages), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDCSLegacy(t *testing.T) {
+	data := "ce4f2d5c24013153012G44+1NN031EXE00013" + "18.2 3.1 +0.4" +
+		"1234ABCD24013153544?38-3NN301WXE00006" + "B1@@Gt"
+
+	messages, err := parseDCSLegacy("legacy.dcs", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("parsed %d messages, want 2", len(messages))
+	}
+
+	tests := []struct {
+		address    string
+		start      time.Time
+		failure    string
+		parity     bool
+		signal     float64
+		offset     float64
+		channel    int
+		spacecraft string
+		data       string
+	}{
+		{"CE4F2D5C", time.Date(2024, 1, 13, 15, 30, 12, 0, time.UTC), "G", false, 44, 50, 31, "E", "18.2 3.1 +0.4"},
+		{"1234ABCD", time.Date(2024, 1, 13, 15, 35, 44, 0, time.UTC), "?", true, 38, -150, 301, "W", "B1@@Gt"},
+	}
+	for i, tt := range tests {
+		msg := messages[i]
+		if msg.File != "legacy.dcs" || msg.Format != "legacy" || msg.PlatformAddress != tt.address {
+			t.Errorf("message %d: file %q, format %q, platform %q", i, msg.File, msg.Format, msg.PlatformAddress)
+		}
+		if !msg.CarrierStart.Equal(tt.start) {
+			t.Errorf("message %d: carrier start %v, want %v", i, msg.CarrierStart, tt.start)
+		}
+		if msg.FailureCode != tt.failure || msg.ParityError != tt.parity {
+			t.Errorf("message %d: failure code %q, parity error %v, want %q, %v", i, msg.FailureCode, msg.ParityError, tt.failure, tt.parity)
+		}
+		if msg.SignalStrength != tt.signal || msg.FrequencyOffset != tt.offset {
+			t.Errorf("message %d: %.0f dBm, %.0f Hz, want %.0f, %.0f", i, msg.SignalStrength, msg.FrequencyOffset, tt.signal, tt.offset)
+		}
+		if msg.Channel != tt.channel || msg.Spacecraft != tt.spacecraft {
+			t.Errorf("message %d: channel %d%s, want %d%s", i, msg.Channel, msg.Spacecraft, tt.channel, tt.spacecraft)
+		}
+		if msg.Data != tt.data {
+			t.Errorf("message %d: data %q, want %q", i, msg.Data, tt.data)
+		}
+	}
+
+	// A length that runs past the end of the file
+	if messages, err := parseDCSLegacy("legacy.dcs", []byte(data[:len(data)-1])); err == nil || len(messages) != 1 {
+		t.Errorf("parseDCSLegacy() of a truncated file = %d messages, %v, want 1 and an error", len(messages), err)
+	}
+}
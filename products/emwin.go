@@ -0,0 +1,146 @@
+package products
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/transport"
+)
+
+// WMO abbreviated heading, e.g. "WUUS53 KMKX 170207" optionally followed by a BBB indicator
+var wmoHeading = regexp.MustCompile(`^([A-Z]{4}[0-9]{2}) ([A-Z0-9]{4}) ([0-9]{6})(?: ([A-Z]{3}))?$`)
+
+// AWIPS product identifier line that follows the WMO heading, e.g. "SVSMKX"
+var awipsID = regexp.MustCompile(`^[A-Z0-9]{4,6}$`)
+
+type bulletin struct {
+	heading string
+	awips   string
+	summary string
+}
+
+type EMWINHandler struct {
+	OutputDir string
+	Filters   []string
+}
+
+func NewEMWINHandler(outputDir string, filters []string) *EMWINHandler {
+	h := EMWINHandler{
+		OutputDir: outputDir,
+	}
+	for _, f := range filters {
+		if f = strings.TrimSpace(f); f != "" {
+			h.Filters = append(h.Filters, strings.ToUpper(f))
+		}
+	}
+	return &h
+}
+
+func (h *EMWINHandler) Handle(f *transport.File) {
+	if f.Headers.Primary.FileType != transport.FileTypeEMWIN {
+		return
+	}
+
+	name := f.Name()
+	if f.Headers.Compression() == transport.CompressionZIP || strings.EqualFold(filepath.Ext(name), ".zip") {
+		h.unzip(name, f.Data)
+		return
+	}
+	h.writeProduct(name, f.Data)
+}
+
+// unzip writes out every product in a zipped EMWIN file. NOAA zips most bulletins individually,
+// but the archive may hold more than one
+func (h *EMWINHandler) unzip(name string, data []byte) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Warnf("[EMWIN] Could not open zipped product %s: %v", name, err)
+		return
+	}
+
+	for _, entry := range archive.File {
+		rc, err := entry.Open()
+		if err != nil {
+			log.Warnf("[EMWIN] Could not read %s from %s: %v", entry.Name, name, err)
+			continue
+		}
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			log.Warnf("[EMWIN] Could not read %s from %s: %v", entry.Name, name, err)
+			continue
+		}
+		h.writeProduct(filepath.Base(entry.Name), contents)
+	}
+}
+
+func (h *EMWINHandler) writeProduct(name string, data []byte) {
+	ext := strings.ToUpper(filepath.Ext(name))
+
+	// Text bulletins are named after their WMO heading and AWIPS ID, which is how everyone else
+	// refers to them. Graphics keep the name NOAA gave them
+	var b *bulletin
+	if ext == ".TXT" || ext == "" {
+		b = parseBulletin(data)
+		if b != nil {
+			name = sanitizeName(b.heading+" "+b.awips) + ".TXT"
+		}
+	}
+
+	path := filepath.Join(h.OutputDir, sanitizeName(name))
+	if err := writeFile(path, data); err != nil {
+		log.Errorf("[EMWIN] Could not write product %s: %v", path, err)
+		return
+	}
+	log.Debugf("[EMWIN] Wrote product: %s", path)
+
+	if b != nil {
+		if filter := h.match(name, data); filter != "" {
+			log.Warnf("[EMWIN] %s %s matched %q: %s", b.heading, b.awips, filter, b.summary)
+		}
+	}
+}
+
+// match returns the first configured filter found in the product's name or text
+func (h *EMWINHandler) match(name string, data []byte) string {
+	upperName := strings.ToUpper(name)
+	upperText := strings.ToUpper(string(data))
+	for _, f := range h.Filters {
+		if strings.Contains(upperName, f) || strings.Contains(upperText, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// parseBulletin finds the WMO heading and AWIPS ID at the top of a text product, along with the
+// first line of the body to summarize it with
+func parseBulletin(data []byte) *bulletin {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r", ""), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !wmoHeading.MatchString(line) {
+			continue
+		}
+
+		b := bulletin{heading: line}
+		rest := lines[i+1:]
+		if len(rest) > 0 && awipsID.MatchString(strings.TrimSpace(rest[0])) {
+			b.awips = strings.TrimSpace(rest[0])
+			rest = rest[1:]
+		}
+		for _, l := range rest {
+			if l = strings.TrimSpace(l); l != "" {
+				b.summary = l
+				break
+			}
+		}
+		return &b
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package products
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/datalink"
+	"github.com/jrwynneiii/goestuner/transport"
+)
+
+// Channels used to build the false color composite: ABI band 2 (red visible) and band 13 (clean
+// long-wave IR)
+const (
+	falseColorVisVCID = 2
+	falseColorIRVCID  = 13
+)
+
+// How long a segmented image may go without receiving a new segment before we give up on it and
+// write out whatever we have
+const segmentTimeout = 15 * time.Minute
+
+// How far apart the visible and IR images may be taken and still be combined into a composite
+const falseColorMaxSkew = 10 * time.Minute
+
+type imageKey struct {
+	vcid    int
+	imageID int
+}
+
+type segmentedImage struct {
+	name       string
+	img        *image.Gray
+	segments   map[int]bool
+	maxSegment int
+	updated    time.Time
+}
+
+type renderedImage struct {
+	img       *image.Gray
+	timestamp time.Time
+}
+
+type ImageHandler struct {
+	OutputDir  string
+	Format     string
+	FalseColor bool
+	inProgress map[imageKey]*segmentedImage
+	latest     map[int]renderedImage
+}
+
+func NewImageHandler(outputDir string, format string, falseColor bool) *ImageHandler {
+	format = strings.ToLower(format)
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	if format != "jpeg" {
+		format = "png"
+	}
+
+	h := ImageHandler{
+		OutputDir:  outputDir,
+		Format:     format,
+		FalseColor: falseColor,
+		inProgress: make(map[imageKey]*segmentedImage),
+		latest:     make(map[int]renderedImage),
+	}
+	return &h
+}
+
+func (h *ImageHandler) Handle(f *transport.File) {
+	h.expireSegments()
+
+	if f.Headers.Primary.FileType != transport.FileTypeImage {
+		return
+	}
+
+	switch f.Headers.Compression() {
+	case transport.CompressionJPEG:
+		h.writeRaw(f, ".jpg")
+	case transport.CompressionGIF:
+		h.writeRaw(f, ".gif")
+	case transport.CompressionNone:
+		h.handleRaster(f)
+	default:
+		log.Debugf("[Products] Skipping image %s with unsupported compression %d", f.Name(), f.Headers.Compression())
+	}
+}
+
+// writeRaw writes images that NOAA already sends in a standard image format straight to disk
+func (h *ImageHandler) writeRaw(f *transport.File, ext string) {
+	path := filepath.Join(channelDir(h.OutputDir, f.VCID), sanitizeName(strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())))+ext)
+	if err := writeFile(path, f.Data); err != nil {
+		log.Errorf("[Products] Could not write image %s: %v", path, err)
+		return
+	}
+	log.Infof("[Products] Wrote image: %s", path)
+}
+
+func (h *ImageHandler) handleRaster(f *transport.File) {
+	is := f.Headers.ImageStructure
+	if is == nil || is.BitsPerPixel != 8 {
+		log.Debugf("[Products] Skipping image %s without an 8 bit image structure header", f.Name())
+		return
+	}
+	if len(f.Data) < is.Columns*is.Lines {
+		log.Warnf("[Products] Image %s is truncated: have %d bytes, want %d", f.Name(), len(f.Data), is.Columns*is.Lines)
+		return
+	}
+
+	segment := &image.Gray{
+		Pix:    f.Data[:is.Columns*is.Lines],
+		Stride: is.Columns,
+		Rect:   image.Rect(0, 0, is.Columns, is.Lines),
+	}
+
+	seg := f.Headers.SegmentIdentification
+	if seg == nil || seg.MaxSegment <= 1 {
+		h.writeImage(f.VCID, sanitizeName(strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))), fileTime(f), segment)
+		return
+	}
+
+	// Stitch the segment into its full image, using the line and column offsets from the segment header
+	key := imageKey{vcid: f.VCID, imageID: seg.ImageID}
+	full, ok := h.inProgress[key]
+	if !ok {
+		full = &segmentedImage{
+			name:       fmt.Sprintf("%s_%s", sanitizeName(datalink.VCIDName(f.VCID)), fileTime(f).Format("20060102T150405Z")),
+			img:        image.NewGray(image.Rect(0, 0, seg.MaxColumn, seg.MaxLine)),
+			segments:   make(map[int]bool),
+			maxSegment: seg.MaxSegment,
+		}
+		h.inProgress[key] = full
+	}
+
+	dst := image.Rect(seg.StartColumn, seg.StartLine, seg.StartColumn+is.Columns, seg.StartLine+is.Lines)
+	if !dst.In(full.img.Rect) {
+		log.Warnf("[Products] Segment %d of %s does not fit in a %dx%d image", seg.SegmentNumber, full.name, seg.MaxColumn, seg.MaxLine)
+		return
+	}
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		copy(full.img.Pix[y*full.img.Stride+dst.Min.X:], segment.Pix[(y-dst.Min.Y)*segment.Stride:(y-dst.Min.Y+1)*segment.Stride])
+	}
+	full.segments[seg.SegmentNumber] = true
+	full.updated = time.Now()
+	log.Debugf("[Products] Image %s: have segment %d (%d of %d)", full.name, seg.SegmentNumber, len(full.segments), full.maxSegment)
+
+	if len(full.segments) >= full.maxSegment {
+		delete(h.inProgress, key)
+		h.writeImage(f.VCID, full.name, fileTime(f), full.img)
+	}
+}
+
+// expireSegments writes out segmented images that stopped receiving segments, so a lost segment
+// doesn't keep the rest of the picture from ever showing up
+func (h *ImageHandler) expireSegments() {
+	for key, full := range h.inProgress {
+		if time.Since(full.updated) > segmentTimeout {
+			delete(h.inProgress, key)
+			log.Warnf("[Products] Image %s timed out with %d of %d segments", full.name, len(full.segments), full.maxSegment)
+			h.writeImage(key.vcid, full.name+"_partial", full.updated, full.img)
+		}
+	}
+}
+
+func (h *ImageHandler) writeImage(vcid int, name string, timestamp time.Time, img image.Image) {
+	path := filepath.Join(channelDir(h.OutputDir, vcid), name+"."+h.extension())
+	if err := h.encode(path, img); err != nil {
+		log.Errorf("[Products] Could not write image %s: %v", path, err)
+		return
+	}
+	log.Infof("[Products] Wrote image: %s", path)
+
+	if gray, ok := img.(*image.Gray); ok && (vcid == falseColorVisVCID || vcid == falseColorIRVCID) {
+		h.latest[vcid] = renderedImage{img: gray, timestamp: timestamp}
+		if h.FalseColor {
+			h.writeFalseColor(timestamp)
+		}
+	}
+}
+
+// writeFalseColor combines the latest visible and IR images into a false color composite, as long as
+// they were taken close enough together to line up
+func (h *ImageHandler) writeFalseColor(timestamp time.Time) {
+	vis, haveVis := h.latest[falseColorVisVCID]
+	ir, haveIR := h.latest[falseColorIRVCID]
+	if !haveVis || !haveIR {
+		return
+	}
+	skew := vis.timestamp.Sub(ir.timestamp)
+	if skew < -falseColorMaxSkew || skew > falseColorMaxSkew {
+		return
+	}
+
+	bounds := vis.img.Bounds()
+	irBounds := ir.img.Bounds()
+	out := image.NewRGBA(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		iy := y * irBounds.Dy() / bounds.Dy()
+		for x := 0; x < bounds.Dx(); x++ {
+			ix := x * irBounds.Dx() / bounds.Dx()
+			v := uint16(vis.img.GrayAt(x, y).Y)
+			i := uint16(ir.img.GrayAt(ix, iy).Y)
+			// Cold cloud tops are bright in the IR band, so they come out white, while warm land
+			// (bright in the visible but dark in the IR) comes out tan and the ocean stays dark
+			out.SetRGBA(x, y, color.RGBA{
+				R: uint8(v),
+				G: uint8((3*v + i) / 4),
+				B: uint8(i),
+				A: 255,
+			})
+		}
+	}
+
+	// Only build each composite once, when the second of the pair arrives
+	delete(h.latest, falseColorVisVCID)
+	delete(h.latest, falseColorIRVCID)
+
+	path := filepath.Join(h.OutputDir, "FalseColor", fmt.Sprintf("FalseColor_%s.%s", timestamp.Format("20060102T150405Z"), h.extension()))
+	if err := h.encode(path, out); err != nil {
+		log.Errorf("[Products] Could not write false color image %s: %v", path, err)
+		return
+	}
+	log.Infof("[Products] Wrote false color image: %s", path)
+}
+
+func (h *ImageHandler) extension() string {
+	if h.Format == "jpeg" {
+		return "jpg"
+	}
+	return "png"
+}
+
+func (h *ImageHandler) encode(path string, img image.Image) error {
+	var buf bytes.Buffer
+	var err error
+	if h.Format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
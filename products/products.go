@@ -0,0 +1,91 @@
+package products
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/config"
+	"github.com/jrwynneiii/goestuner/datalink"
+	"github.com/jrwynneiii/goestuner/transport"
+	"github.com/knadh/koanf/v2"
+)
+
+// Handler is implemented by everything that turns assembled LRIT files into products on disk
+type Handler interface {
+	Handle(f *transport.File)
+}
+
+type Processor struct {
+	FileInput chan *transport.File
+	OutputDir string
+	Admin     *AdminHandler
+	handlers  []Handler
+}
+
+func New(bufsize uint, configFile *koanf.Koanf) *Processor {
+	conf := config.ProductsConf{
+		Enabled:      configFile.Bool("products.enabled"),
+		OutputDir:    configFile.String("products.output_dir"),
+		ImageFormat:  configFile.String("products.image_format"),
+		FalseColor:   configFile.Bool("products.false_color"),
+		EMWINFilters: configFile.Strings("products.emwin_filters"),
+		DCSPlatforms: configFile.Strings("products.dcs_platforms"),
+	}
+	if conf.OutputDir == "" {
+		conf.OutputDir = "./products"
+	}
+
+	log.Debugf("Found products definition: %##v", conf)
+
+	p := Processor{
+		FileInput: make(chan *transport.File, bufsize),
+		OutputDir: conf.OutputDir,
+		Admin:     NewAdminHandler(filepath.Join(conf.OutputDir, "admin")),
+	}
+	p.handlers = append(p.handlers, NewImageHandler(filepath.Join(conf.OutputDir, "images"), conf.ImageFormat, conf.FalseColor))
+	p.handlers = append(p.handlers, NewEMWINHandler(filepath.Join(conf.OutputDir, "emwin"), conf.EMWINFilters))
+	p.handlers = append(p.handlers, p.Admin)
+	p.handlers = append(p.handlers, NewDCSHandler(filepath.Join(conf.OutputDir, "dcs"), conf.DCSPlatforms))
+
+	return &p
+}
+
+func (p *Processor) Start() {
+	for f := range p.FileInput {
+		for _, h := range p.handlers {
+			h.Handle(f)
+		}
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeName makes a string safe to use as a file or directory name
+func sanitizeName(name string) string {
+	return strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_")
+}
+
+// channelDir returns the directory products from a virtual channel are written to, named after the channel
+func channelDir(base string, vcid int) string {
+	return filepath.Join(base, sanitizeName(fmt.Sprintf("%02d-%s", vcid, datalink.VCIDName(vcid))))
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// fileTime returns when the product was created, falling back to when we received it
+func fileTime(f *transport.File) time.Time {
+	if !f.Headers.TimeStamp.IsZero() {
+		return f.Headers.TimeStamp
+	}
+	return f.Received.UTC()
+}
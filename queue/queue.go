@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Stats counts what happened to the blocks sent on one of the channels between two stages. It's safe to
+// read while the sender is still using it
+type Stats struct {
+	sent    atomic.Int64
+	dropped atomic.Int64
+	stalls  atomic.Int64
+}
+
+// Sent is how many blocks made it on to the channel
+func (s *Stats) Sent() int64 {
+	return s.sent.Load()
+}
+
+// Dropped is how many blocks were thrown away because the channel was full
+func (s *Stats) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Stalls is how many times the sender had to wait for room on the channel
+func (s *Stats) Stalls() int64 {
+	return s.stalls.Load()
+}
+
+func (s *Stats) Reset() {
+	s.sent.Store(0)
+	s.dropped.Store(0)
+	s.stalls.Store(0)
+}
+
+// TrySend sends v on ch if there's room, and drops it otherwise. It's for senders that can't be held
+// up, like the radio, which would lose samples inside the SDR instead
+func TrySend[T any](ch chan T, v T, stats *Stats) bool {
+	select {
+	case ch <- v:
+		stats.sent.Add(1)
+		return true
+	default:
+		stats.dropped.Add(1)
+		return false
+	}
+}
+
+// Send sends v on ch, waiting for room if it's full so the receiver can push back on the sender. It
+// returns false if ctx is cancelled first
+func Send[T any](ctx context.Context, ch chan T, v T, stats *Stats) bool {
+	select {
+	case ch <- v:
+		stats.sent.Add(1)
+		return true
+	default:
+	}
+
+	stats.stalls.Add(1)
+	select {
+	case ch <- v:
+		stats.sent.Add(1)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
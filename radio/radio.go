@@ -3,10 +3,14 @@ package radio
 // #cgo CFLAGS: -g -Wall
 // #cgo LDFLAGS: -lSoapySDR
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/jrwynneiii/goestuner/config"
+	"github.com/jrwynneiii/goestuner/queue"
 
 	"github.com/pothosware/go-soapy-sdr/pkg/device"
 	"github.com/pothosware/go-soapy-sdr/pkg/modules"
@@ -42,12 +46,18 @@ type Radio[T StreamConstraint] struct {
 	BufferCF32    [][]complex64
 	BufferCF64    [][]complex128
 	Frequency     float64
+	// Counts the sample blocks handed to the demodulator, and dropped because it was behind
+	OutputStats queue.Stats
 	//Private:
 	chunksize uint
 	args      map[string]string
 	device    *device.SDRDevice
 	stream    any
-	Stopping  bool
+	// Set while the stream is paused or being torn down, so nothing reads from it
+	Stopping atomic.Bool
+	// Held by Start around each Read, and by anything that sets up, closes or swaps out the stream or its
+	// buffers, so they're never pulled out from under a Read
+	streamMutex sync.Mutex
 }
 
 func InitSoapySDR() {
@@ -122,19 +132,40 @@ func LogAllSoapySDRDevices() {
 
 }
 
-func (r *Radio[T]) Start() {
+// Start reads samples from the SDR until ctx is cancelled. It then closes SamplesOutput, so the
+// demodulator knows nothing more is coming. Read blocks until the SDR has samples for us, and if the
+// demodulator can't keep up, blocks are dropped rather than holding up the SDR
+func (r *Radio[T]) Start(ctx context.Context) {
+	defer close(*r.SamplesOutput)
 	var buf []complex64
 	for {
-		if !r.Stopping {
-			samples := r.Read(r.chunksize)
-			buf = append(buf, samples.([]complex64)...)
+		select {
+		case <-ctx.Done():
+			log.Debug("Radio stopping")
+			return
+		default:
+		}
+
+		r.streamMutex.Lock()
+		if r.Stopping.Load() {
+			r.streamMutex.Unlock()
+			// Paused, so there's no stream to block on. Anything read before the pause is stale by the
+			// time it's reconnected
+			buf = nil
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
 
-			if len(buf) >= int(r.chunksize) {
-				*r.SamplesOutput <- buf
-				buf = []complex64{}
+		samples := r.Read(r.chunksize)
+		buf = append(buf, samples.([]complex64)...)
+
+		if len(buf) >= int(r.chunksize) {
+			if !queue.TrySend(*r.SamplesOutput, buf, &r.OutputStats) {
+				log.Debug("Demodulator is falling behind, dropping samples")
 			}
+			buf = []complex64{}
 		}
-		time.Sleep(5 * time.Millisecond)
+		r.streamMutex.Unlock()
 	}
 
 }
@@ -161,8 +192,13 @@ func New[T StreamConstraint](conf config.RadioConf, driver string, stype StreamT
 	return &r
 }
 
+// Pause waits for Start to finish the Read it's in, parks it, and closes the stream. Nothing more is sent
+// on SamplesOutput until Connect opens a new one
 func (r *Radio[T]) Pause() {
-	r.Stopping = true
+	r.streamMutex.Lock()
+	defer r.streamMutex.Unlock()
+
+	r.Stopping.Store(true)
 	r.StreamDeactivate()
 	r.StreamClose()
 	r.BufferCF32 = make([][]complex64, 1)
@@ -171,11 +207,11 @@ func (r *Radio[T]) Pause() {
 
 func (r *Radio[T]) Read(num uint) any {
 	flags := make([]int, 1)
-	timeout := uint(100000) //nanosec
+	timeout := uint(100000) //microsec
 
 	switch r.SampleType {
 	case CF32:
-		if !r.Stopping {
+		if !r.Stopping.Load() {
 			timeNs, numSamples, err := r.stream.(*device.SDRStreamCF32).Read(r.BufferCF32, num, flags, timeout)
 			log.Debugf("timeNs: %v, numSamples: %v, err: %v", timeNs, numSamples, err)
 			return r.BufferCF32[0][:numSamples]
@@ -209,6 +245,9 @@ func LogAvailSettings(dev *device.SDRDevice) {
 }
 
 func (r *Radio[T]) Connect() {
+	r.streamMutex.Lock()
+	defer r.streamMutex.Unlock()
+
 	r.args = make(map[string]string)
 	r.args["driver"] = r.Driver
 	if r.Driver == "rtltcp" {
@@ -263,7 +302,7 @@ func (r *Radio[T]) StreamActivate() {
 		}
 	}
 	//Read the first few samples and discard to make sure we have clean data
-	r.Stopping = false
+	r.Stopping.Store(false)
 	r.Read(1024)
 	if len(r.BufferCU8) > 0 {
 		clear(r.BufferCU8[0])
@@ -306,12 +345,25 @@ func (r *Radio[T]) StreamClose() {
 				log.Fatalf("Could not close the IQ stream! %s", err.Error())
 			}
 		}
+		// Connect sets up a new stream, so don't let anything touch the closed one
+		r.stream = nil
 	}
 }
 
+// Destroy closes the IQ stream and releases the SDR. Start must have returned first, since it's the one
+// reading from the stream
 func (r *Radio[T]) Destroy() {
-	r.Stopping = true
+	r.streamMutex.Lock()
+	defer r.streamMutex.Unlock()
+
+	r.Stopping.Store(true)
 	r.StreamDeactivate()
 	r.StreamClose()
-	close(*r.SamplesOutput)
+	if r.device != nil {
+		log.Debug("Releasing SDR device...")
+		if err := r.device.Unmake(); err != nil {
+			log.Errorf("Could not release the SDR device: %v", err)
+		}
+		r.device = nil
+	}
 }
@@ -0,0 +1,214 @@
+package transport
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// Size of a GOES HRIT VCDU once the sync word and Reed-Solomon parity have been stripped
+const VCDUSize = 892
+
+// VCID used by fill frames
+const FillVCID = 63
+
+// "No packet header in this zone" value of the M_PDU first header pointer
+const noHeaderPointer = 2047
+
+type virtualChannel struct {
+	counter    int
+	synced     bool
+	pending    []byte
+	assemblers map[int]*fileAssembler
+}
+
+type Demuxer struct {
+	FrameInput           chan []byte
+	FilesOutput          *chan *File
+	StatsMutex           sync.RWMutex
+	FramesProcessed      int
+	FrameDiscontinuities int
+	PacketsProcessed     int
+	PacketsCRCFailed     int
+	FilesAssembled       int
+	FilesDropped         int
+	channels             map[int]*virtualChannel
+}
+
+func New(bufsize uint, output *chan *File) *Demuxer {
+	d := Demuxer{
+		FrameInput:  make(chan []byte, bufsize),
+		FilesOutput: output,
+		channels:    make(map[int]*virtualChannel),
+	}
+	return &d
+}
+
+// Start demultiplexes frames until the decoder closes FrameInput, then closes FilesOutput
+func (d *Demuxer) Start() {
+	defer close(*d.FilesOutput)
+	for frame := range d.FrameInput {
+		d.processFrame(frame)
+	}
+}
+
+func (d *Demuxer) processFrame(frame []byte) {
+	if len(frame) < VCDUSize {
+		return
+	}
+
+	vcid := int(frame[1] & 0x3F)
+	if vcid == FillVCID {
+		return
+	}
+	counter := int(frame[2])<<16 | int(frame[3])<<8 | int(frame[4])
+	fhp := int(binary.BigEndian.Uint16(frame[6:8]) & 0x07FF)
+	zone := frame[8:VCDUSize]
+
+	vc, ok := d.channels[vcid]
+	if !ok {
+		vc = &virtualChannel{
+			counter:    -1,
+			assemblers: make(map[int]*fileAssembler),
+		}
+		d.channels[vcid] = vc
+	}
+
+	d.StatsMutex.Lock()
+	d.FramesProcessed++
+	d.StatsMutex.Unlock()
+
+	// If we missed a frame, whatever packet we were in the middle of is gone, as are the files being
+	// assembled on this channel
+	if vc.counter >= 0 && (vc.counter+1)&0xFFFFFF != counter {
+		log.Debugf("[Transport] VCID %d frame counter jumped from %d to %d", vcid, vc.counter, counter)
+		d.StatsMutex.Lock()
+		d.FrameDiscontinuities++
+		d.StatsMutex.Unlock()
+		vc.synced = false
+		vc.pending = nil
+		for _, a := range vc.assemblers {
+			d.dropFile(a)
+		}
+	}
+	vc.counter = counter
+
+	if fhp == noHeaderPointer {
+		if vc.synced {
+			vc.pending = append(vc.pending, zone...)
+			d.extractPackets(vcid, vc)
+		}
+		return
+	}
+
+	if fhp >= len(zone) {
+		log.Debugf("[Transport] VCID %d has an invalid first header pointer: %d", vcid, fhp)
+		vc.synced = false
+		vc.pending = nil
+		return
+	}
+
+	// Finish off the packet that spilled over from the previous frame
+	if vc.synced {
+		vc.pending = append(vc.pending, zone[:fhp]...)
+		d.extractPackets(vcid, vc)
+		if len(vc.pending) > 0 {
+			log.Debugf("[Transport] VCID %d has %d leftover bytes before the first header", vcid, len(vc.pending))
+		}
+	}
+
+	vc.pending = append([]byte{}, zone[fhp:]...)
+	vc.synced = true
+	d.extractPackets(vcid, vc)
+}
+
+// extractPackets pulls every complete packet off the front of the channel's pending buffer
+func (d *Demuxer) extractPackets(vcid int, vc *virtualChannel) {
+	for len(vc.pending) >= packetHeaderSize {
+		length := packetLength(vc.pending)
+		if len(vc.pending) < length {
+			return
+		}
+
+		packet := parsePacket(vc.pending[:length])
+		vc.pending = vc.pending[length:]
+
+		if packet.APID == FillAPID {
+			continue
+		}
+
+		d.StatsMutex.Lock()
+		d.PacketsProcessed++
+		if !packet.CRCGood {
+			d.PacketsCRCFailed++
+		}
+		d.StatsMutex.Unlock()
+
+		a, ok := vc.assemblers[packet.APID]
+		if !ok {
+			a = &fileAssembler{vcid: vcid, apid: packet.APID, lastCount: -1}
+			vc.assemblers[packet.APID] = a
+		}
+
+		if file := d.assemble(a, packet); file != nil {
+			d.StatsMutex.Lock()
+			d.FilesAssembled++
+			d.StatsMutex.Unlock()
+			log.Debugf("[Transport] Assembled file: vcid: %d apid: %d counter: %d (%s)", file.VCID, file.APID, file.Counter, file.Headers.Annotation)
+			if d.FilesOutput != nil {
+				*d.FilesOutput <- file
+			}
+		}
+	}
+}
+
+func (d *Demuxer) assemble(a *fileAssembler, p Packet) *File {
+	// Missing packets or a bad CRC mean the file in progress can't be trusted
+	gap := a.lastCount >= 0 && (a.lastCount+1)&0x3FFF != p.SequenceCount
+	a.lastCount = p.SequenceCount
+	if gap || !p.CRCGood {
+		d.dropFile(a)
+		return nil
+	}
+
+	switch p.SequenceFlag {
+	case SequenceFirst:
+		d.dropFile(a)
+		if err := a.begin(p.Data); err != nil {
+			log.Debugf("[Transport] VCID %d APID %d: %v", a.vcid, a.apid, err)
+			d.dropFile(a)
+		}
+	case SequenceContinuation:
+		if err := a.add(p.Data); err != nil {
+			log.Debugf("[Transport] VCID %d APID %d: %v", a.vcid, a.apid, err)
+			d.dropFile(a)
+		}
+	case SequenceLast:
+		if err := a.add(p.Data); err != nil {
+			log.Debugf("[Transport] VCID %d APID %d: %v", a.vcid, a.apid, err)
+			d.dropFile(a)
+			return nil
+		}
+		return a.finish()
+	case SequenceStandalone:
+		d.dropFile(a)
+		if err := a.begin(p.Data); err != nil {
+			log.Debugf("[Transport] VCID %d APID %d: %v", a.vcid, a.apid, err)
+			d.dropFile(a)
+			return nil
+		}
+		return a.finish()
+	}
+	return nil
+}
+
+func (d *Demuxer) dropFile(a *fileAssembler) {
+	if a.file == nil {
+		return
+	}
+	d.StatsMutex.Lock()
+	d.FilesDropped++
+	d.StatsMutex.Unlock()
+	a.reset()
+}
@@ -0,0 +1,258 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+)
+
+const testVCID = 2
+
+// headerRecord builds an LRIT header record
+func headerRecord(htype int, payload []byte) []byte {
+	rec := []byte{byte(htype), 0, 0}
+	binary.BigEndian.PutUint16(rec[1:3], uint16(3+len(payload)))
+	return append(rec, payload...)
+}
+
+// lritFile builds the start of an LRIT file, as carried by the first packet of a sequence: the
+// transport header, the primary header and then the other header records
+func lritFile(counter, fileType int, dataBits uint64, records ...[]byte) []byte {
+	headerLength := 16
+	for _, rec := range records {
+		headerLength += len(rec)
+	}
+	f := make([]byte, transportHeaderSize+16)
+	binary.BigEndian.PutUint16(f[0:2], uint16(counter))
+	primary := f[transportHeaderSize:]
+	primary[0] = HeaderPrimary
+	binary.BigEndian.PutUint16(primary[1:3], 16)
+	primary[3] = byte(fileType)
+	binary.BigEndian.PutUint32(primary[4:8], uint32(headerLength))
+	binary.BigEndian.PutUint64(primary[8:16], dataBits)
+	for _, rec := range records {
+		f = append(f, rec...)
+	}
+	return f
+}
+
+// testStream is a run of packets on one virtual channel, before it's cut up into frames
+type testStream struct {
+	data   []byte
+	starts []int
+}
+
+func (s *testStream) add(packet []byte) {
+	s.starts = append(s.starts, len(s.data))
+	s.data = append(s.data, packet...)
+}
+
+// frames cuts the stream up into VCDUs, filling out the last one with a fill packet
+func (s *testStream) frames(counter int) [][]byte {
+	zoneSize := VCDUSize - 8
+	pad := zoneSize - len(s.data)%zoneSize
+	if pad < packetHeaderSize+2 {
+		pad += zoneSize
+	}
+	s.add(spacePacket(FillAPID, SequenceStandalone, 0, make([]byte, pad-packetHeaderSize-2)))
+
+	var frames [][]byte
+	for offset := 0; offset < len(s.data); offset += zoneSize {
+		frame := make([]byte, 8, VCDUSize)
+		frame[0] = 0x40
+		frame[1] = testVCID
+		frame[2], frame[3], frame[4] = byte(counter>>16), byte(counter>>8), byte(counter)
+		fhp := noHeaderPointer
+		for _, start := range s.starts {
+			if start >= offset && start < offset+zoneSize {
+				fhp = start - offset
+				break
+			}
+		}
+		binary.BigEndian.PutUint16(frame[6:8], uint16(fhp))
+		frames = append(frames, append(frame, s.data[offset:offset+zoneSize]...))
+		counter = (counter + 1) & 0xFFFFFF
+	}
+	return frames
+}
+
+type testImage struct {
+	columns, lines, linesPerPacket int
+	pixels                         []byte
+}
+
+// packets splits the image into Rice compressed packets, led by a packet with the headers
+func (img testImage) packets(apid, counter int) [][]byte {
+	flags := make([]byte, 2)
+	binary.BigEndian.PutUint16(flags, riceOptionNN)
+	structure := []byte{8, 0, 0, 0, 0, CompressionRice}
+	binary.BigEndian.PutUint16(structure[1:3], uint16(img.columns))
+	binary.BigEndian.PutUint16(structure[3:5], uint16(img.lines))
+	noaa := append([]byte("NOAA"), 0, 16, 0, 13, 0, 0, CompressionRice)
+
+	header := lritFile(counter, FileTypeImage, uint64(len(img.pixels)*8),
+		headerRecord(HeaderImageStructure, structure),
+		headerRecord(HeaderAnnotation, []byte("OR_ABI-L2-CMIPF-M6C13_G16_s20262911200.lrit")),
+		headerRecord(HeaderNOAASpecific, noaa),
+		headerRecord(HeaderRiceCompression, append(flags, 16, byte(img.linesPerPacket))),
+	)
+	packets := [][]byte{spacePacket(apid, SequenceFirst, 0, header)}
+
+	for line := 0; line < img.lines; line += img.linesPerPacket {
+		var lines [][]byte
+		for y := line; y < min(line+img.linesPerPacket, img.lines); y++ {
+			lines = append(lines, img.pixels[y*img.columns:(y+1)*img.columns])
+		}
+		flag := SequenceContinuation
+		if line+img.linesPerPacket >= img.lines {
+			flag = SequenceLast
+		}
+		packets = append(packets, spacePacket(apid, flag, len(packets), riceEncode(lines, 8, 16, true)))
+	}
+	return packets
+}
+
+func newTestImage(rng *rand.Rand) testImage {
+	img := testImage{columns: 500, lines: 7, linesPerPacket: 2}
+	img.pixels = make([]byte, img.columns*img.lines)
+	for i := range img.pixels {
+		img.pixels[i] = byte(i%img.columns/2 + rng.IntN(40))
+	}
+	return img
+}
+
+func textFile(counter int, text string) []byte {
+	header := lritFile(counter, FileTypeText, uint64(len(text)*8), headerRecord(HeaderAnnotation, []byte("message.txt")))
+	return append(header, text...)
+}
+
+func demux(frames [][]byte) (*Demuxer, []*File) {
+	files := make(chan *File, 8)
+	d := New(0, &files)
+	for _, frame := range frames {
+		d.processFrame(frame)
+	}
+	close(files)
+
+	var out []*File
+	for f := range files {
+		out = append(out, f)
+	}
+	return d, out
+}
+
+func TestDemuxRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(26, 1))
+	img := newTestImage(rng)
+
+	var s testStream
+	imagePackets := img.packets(300, 41)
+	for _, p := range imagePackets {
+		s.add(p)
+	}
+	s.add(spacePacket(FillAPID, SequenceStandalone, 0, make([]byte, 100)))
+	s.add(spacePacket(301, SequenceStandalone, 7, textFile(42, "Hello from GOES")))
+	frames := s.frames(0xFFFFFE)
+
+	// Fill frames on their own virtual channel don't count, wherever they turn up
+	fill := make([]byte, VCDUSize)
+	fill[1] = FillVCID
+	frames = append(frames[:2], append([][]byte{fill}, frames[2:]...)...)
+
+	d, files := demux(frames)
+	if len(files) != 2 {
+		t.Fatalf("assembled %d files, want 2", len(files))
+	}
+
+	image := files[0]
+	if image.APID != 300 || image.Counter != 41 || image.Name() != "OR_ABI-L2-CMIPF-M6C13_G16_s20262911200.lrit" {
+		t.Errorf("image file is APID %d, counter %d, %q", image.APID, image.Counter, image.Name())
+	}
+	if !bytes.Equal(image.Data, img.pixels) {
+		t.Error("image data didn't survive the round trip")
+	}
+	if image.Headers.Compression() != CompressionNone || image.Headers.ImageStructure.Compression != CompressionNone {
+		t.Error("decompressed image still advertises Rice compression")
+	}
+	if image.Headers.ImageStructure.Columns != img.columns || image.Headers.ImageStructure.Lines != img.lines {
+		t.Errorf("image is %dx%d, want %dx%d", image.Headers.ImageStructure.Columns, image.Headers.ImageStructure.Lines, img.columns, img.lines)
+	}
+
+	text := files[1]
+	if text.Headers.Primary.FileType != FileTypeText || string(text.Data) != "Hello from GOES" || text.Name() != "message.txt" {
+		t.Errorf("text file is type %d, %q, %q", text.Headers.Primary.FileType, text.Name(), text.Data)
+	}
+
+	if d.FramesProcessed != len(frames)-1 || d.FrameDiscontinuities != 0 {
+		t.Errorf("processed %d frames with %d discontinuities, want %d and 0", d.FramesProcessed, d.FrameDiscontinuities, len(frames)-1)
+	}
+	if want := len(imagePackets) + 1; d.PacketsProcessed != want || d.PacketsCRCFailed != 0 {
+		t.Errorf("processed %d packets with %d CRC failures, want %d and 0", d.PacketsProcessed, d.PacketsCRCFailed, want)
+	}
+	if d.FilesAssembled != 2 || d.FilesDropped != 0 {
+		t.Errorf("assembled %d files and dropped %d, want 2 and 0", d.FilesAssembled, d.FilesDropped)
+	}
+}
+
+type demuxCounts struct {
+	FrameDiscontinuities int
+	PacketsCRCFailed     int
+	FilesAssembled       int
+	FilesDropped         int
+}
+
+func TestDemuxDropsDamagedFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		// Damages the stream or its frames
+		damage func(s *testStream, frames [][]byte) [][]byte
+		want   demuxCounts
+	}{
+		{
+			"missing frame",
+			func(_ *testStream, frames [][]byte) [][]byte {
+				return append(frames[:2], frames[3:]...)
+			},
+			demuxCounts{FrameDiscontinuities: 1, FilesAssembled: 1, FilesDropped: 1},
+		},
+		{
+			"bad CRC",
+			func(s *testStream, frames [][]byte) [][]byte {
+				// The second packet of the image, which the first frame carries all of
+				frames[0][8+s.starts[1]+packetHeaderSize+10] ^= 0x01
+				return frames
+			},
+			demuxCounts{PacketsCRCFailed: 1, FilesAssembled: 1, FilesDropped: 1},
+		},
+		{
+			"missing packet",
+			func(s *testStream, frames [][]byte) [][]byte {
+				// Overwrite the second packet's sequence count, as if the one before it went missing
+				binary.BigEndian.PutUint16(frames[0][8+s.starts[1]+2:], SequenceContinuation<<14|5)
+				return frames
+			},
+			// The CRC doesn't cover the header, so only the sequence count gives it away
+			demuxCounts{FilesAssembled: 1, FilesDropped: 1},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(26, uint64(i)))
+			var s testStream
+			for _, p := range newTestImage(rng).packets(300, 1) {
+				s.add(p)
+			}
+			s.add(spacePacket(301, SequenceStandalone, 0, textFile(2, "Still here")))
+
+			d, files := demux(tt.damage(&s, s.frames(0)))
+			if len(files) != 1 || string(files[0].Data) != "Still here" {
+				t.Errorf("assembled %d files, want just the text file", len(files))
+			}
+			got := demuxCounts{d.FrameDiscontinuities, d.PacketsCRCFailed, d.FilesAssembled, d.FilesDropped}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Size of the transport header that precedes the LRIT headers in the first packet of each file
+const transportHeaderSize = 10
+
+// File is a fully reassembled LRIT file. Rice compressed image data is decompressed on the fly while
+// the file is assembled, so Data always holds the plain data field
+type File struct {
+	VCID     int
+	APID     int
+	Counter  int
+	Headers  *Headers
+	Header   []byte
+	Data     []byte
+	Received time.Time
+}
+
+// Name returns the file name NOAA put in the annotation header, or a generated name if there is none
+func (f *File) Name() string {
+	if f.Headers.Annotation != "" {
+		return f.Headers.Annotation
+	}
+	return fmt.Sprintf("vcid%d_apid%d_%d.lrit", f.VCID, f.APID, f.Counter)
+}
+
+type fileAssembler struct {
+	vcid           int
+	apid           int
+	lastCount      int
+	file           *File
+	rice           *RiceDecoder
+	linesPerPacket int
+	linesLeft      int
+}
+
+func (a *fileAssembler) reset() {
+	a.file = nil
+	a.rice = nil
+}
+
+// begin starts a new file from the first packet of a sequence, which holds the transport header and
+// all of the LRIT headers
+func (a *fileAssembler) begin(data []byte) error {
+	if len(data) < transportHeaderSize {
+		return fmt.Errorf("First packet too short for a transport header: %d bytes", len(data))
+	}
+	counter := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[transportHeaderSize:]
+
+	headers, err := ParseHeaders(data)
+	if err != nil {
+		return err
+	}
+
+	f := File{
+		VCID:     a.vcid,
+		APID:     a.apid,
+		Counter:  counter,
+		Headers:  headers,
+		Header:   append([]byte{}, data[:headers.Primary.HeaderLength]...),
+		Data:     append([]byte{}, data[headers.Primary.HeaderLength:]...),
+		Received: time.Now(),
+	}
+
+	if headers.Compression() == CompressionRice && headers.RiceCompression != nil && headers.ImageStructure != nil {
+		rice, err := NewRiceDecoder(headers.RiceCompression, headers.ImageStructure.BitsPerPixel, headers.ImageStructure.Columns)
+		if err != nil {
+			return err
+		}
+		a.rice = rice
+		a.linesPerPacket = max(1, headers.RiceCompression.ScanLinesPerPacket)
+		a.linesLeft = headers.ImageStructure.Lines
+		f.Data = make([]byte, 0, headers.ImageStructure.Columns*headers.ImageStructure.Lines)
+	}
+
+	a.file = &f
+	return nil
+}
+
+func (a *fileAssembler) add(data []byte) error {
+	if a.file == nil {
+		// We came in part way through a file, so there's nothing to add to
+		return nil
+	}
+
+	if a.rice == nil {
+		a.file.Data = append(a.file.Data, data...)
+		return nil
+	}
+
+	lines := min(a.linesPerPacket, a.linesLeft)
+	if lines <= 0 {
+		return fmt.Errorf("Received more image packets than the image has lines")
+	}
+	pixels, err := a.rice.Decompress(data, lines)
+	if err != nil {
+		return err
+	}
+	a.file.Data = append(a.file.Data, pixels...)
+	a.linesLeft -= lines
+	return nil
+}
+
+func (a *fileAssembler) finish() *File {
+	f := a.file
+	decompressed := a.rice != nil
+	a.reset()
+	if f == nil {
+		return nil
+	}
+
+	// The data field no longer matches the compression advertised by the headers
+	if decompressed {
+		if f.Headers.ImageStructure != nil {
+			f.Headers.ImageStructure.Compression = CompressionNone
+		}
+		if f.Headers.NOAASpecific != nil {
+			f.Headers.NOAASpecific.Compression = CompressionNone
+		}
+	}
+	return f
+}
@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LRIT header record types, as defined in the LRIT/HRIT global specification and the GOES-R HRIT
+// NOAA specific extensions
+const (
+	HeaderPrimary               = 0
+	HeaderImageStructure        = 1
+	HeaderImageNavigation       = 2
+	HeaderImageDataFunction     = 3
+	HeaderAnnotation            = 4
+	HeaderTimeStamp             = 5
+	HeaderAncillaryText         = 6
+	HeaderKey                   = 7
+	HeaderSegmentIdentification = 128
+	HeaderNOAASpecific          = 129
+	HeaderStructureRecord       = 130
+	HeaderRiceCompression       = 131
+)
+
+// LRIT file type codes
+const (
+	FileTypeImage      = 0
+	FileTypeGTSMessage = 1
+	FileTypeText       = 2
+	FileTypeDCS        = 130
+	FileTypeEMWIN      = 214
+)
+
+// NOAA specific compression flags
+const (
+	CompressionNone = 0
+	CompressionRice = 1
+	CompressionJPEG = 2
+	CompressionGIF  = 5
+	CompressionZIP  = 10
+)
+
+type PrimaryHeader struct {
+	FileType     int
+	HeaderLength uint32
+	DataLength   uint64
+}
+
+type ImageStructureHeader struct {
+	BitsPerPixel int
+	Columns      int
+	Lines        int
+	Compression  int
+}
+
+type ImageNavigationHeader struct {
+	ProjectionName string
+	ColumnScaling  int32
+	LineScaling    int32
+	ColumnOffset   int32
+	LineOffset     int32
+}
+
+type SegmentIdentificationHeader struct {
+	ImageID       int
+	SegmentNumber int
+	StartColumn   int
+	StartLine     int
+	MaxSegment    int
+	MaxColumn     int
+	MaxLine       int
+}
+
+type NOAASpecificHeader struct {
+	Agency       string
+	ProductID    int
+	ProductSubID int
+	Parameter    int
+	Compression  int
+}
+
+type RiceCompressionHeader struct {
+	Flags              uint16
+	PixelsPerBlock     int
+	ScanLinesPerPacket int
+}
+
+// Headers holds every LRIT header record we know how to parse from the beginning of a file. Optional
+// headers are nil when the file did not carry them
+type Headers struct {
+	Primary               PrimaryHeader
+	ImageStructure        *ImageStructureHeader
+	ImageNavigation       *ImageNavigationHeader
+	ImageDataFunction     string
+	Annotation            string
+	TimeStamp             time.Time
+	AncillaryText         string
+	SegmentIdentification *SegmentIdentificationHeader
+	NOAASpecific          *NOAASpecificHeader
+	HeaderStructure       string
+	RiceCompression       *RiceCompressionHeader
+}
+
+// CCSDS day segmented time codes count days from 1958-01-01
+var ccsdsEpoch = time.Date(1958, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ParseHeaders walks the header records at the start of an LRIT file. The primary header must come
+// first, and tells us how many bytes of headers there are in total
+func ParseHeaders(data []byte) (*Headers, error) {
+	if len(data) < 16 || data[0] != HeaderPrimary {
+		return nil, fmt.Errorf("File does not start with a primary header")
+	}
+
+	h := Headers{
+		Primary: PrimaryHeader{
+			FileType:     int(data[3]),
+			HeaderLength: binary.BigEndian.Uint32(data[4:8]),
+			DataLength:   binary.BigEndian.Uint64(data[8:16]),
+		},
+	}
+
+	if int(h.Primary.HeaderLength) > len(data) {
+		return nil, fmt.Errorf("Header length %d exceeds file size %d", h.Primary.HeaderLength, len(data))
+	}
+
+	pos := 16
+	for pos+3 <= int(h.Primary.HeaderLength) {
+		htype := int(data[pos])
+		hlen := int(binary.BigEndian.Uint16(data[pos+1 : pos+3]))
+		if hlen < 3 || pos+hlen > int(h.Primary.HeaderLength) {
+			return nil, fmt.Errorf("Malformed header record of type %d at offset %d", htype, pos)
+		}
+		rec := data[pos+3 : pos+hlen]
+
+		switch htype {
+		case HeaderImageStructure:
+			if len(rec) >= 6 {
+				h.ImageStructure = &ImageStructureHeader{
+					BitsPerPixel: int(rec[0]),
+					Columns:      int(binary.BigEndian.Uint16(rec[1:3])),
+					Lines:        int(binary.BigEndian.Uint16(rec[3:5])),
+					Compression:  int(rec[5]),
+				}
+			}
+		case HeaderImageNavigation:
+			if len(rec) >= 48 {
+				h.ImageNavigation = &ImageNavigationHeader{
+					ProjectionName: strings.TrimSpace(string(rec[:32])),
+					ColumnScaling:  int32(binary.BigEndian.Uint32(rec[32:36])),
+					LineScaling:    int32(binary.BigEndian.Uint32(rec[36:40])),
+					ColumnOffset:   int32(binary.BigEndian.Uint32(rec[40:44])),
+					LineOffset:     int32(binary.BigEndian.Uint32(rec[44:48])),
+				}
+			}
+		case HeaderImageDataFunction:
+			h.ImageDataFunction = string(rec)
+		case HeaderAnnotation:
+			h.Annotation = strings.TrimRight(string(rec), "\x00 ")
+		case HeaderTimeStamp:
+			if len(rec) >= 7 {
+				days := binary.BigEndian.Uint16(rec[1:3])
+				ms := binary.BigEndian.Uint32(rec[3:7])
+				h.TimeStamp = ccsdsEpoch.AddDate(0, 0, int(days)).Add(time.Duration(ms) * time.Millisecond)
+			}
+		case HeaderAncillaryText:
+			h.AncillaryText = string(rec)
+		case HeaderSegmentIdentification:
+			if len(rec) >= 14 {
+				h.SegmentIdentification = &SegmentIdentificationHeader{
+					ImageID:       int(binary.BigEndian.Uint16(rec[0:2])),
+					SegmentNumber: int(binary.BigEndian.Uint16(rec[2:4])),
+					StartColumn:   int(binary.BigEndian.Uint16(rec[4:6])),
+					StartLine:     int(binary.BigEndian.Uint16(rec[6:8])),
+					MaxSegment:    int(binary.BigEndian.Uint16(rec[8:10])),
+					MaxColumn:     int(binary.BigEndian.Uint16(rec[10:12])),
+					MaxLine:       int(binary.BigEndian.Uint16(rec[12:14])),
+				}
+			}
+		case HeaderNOAASpecific:
+			if len(rec) >= 11 {
+				h.NOAASpecific = &NOAASpecificHeader{
+					Agency:       string(rec[0:4]),
+					ProductID:    int(binary.BigEndian.Uint16(rec[4:6])),
+					ProductSubID: int(binary.BigEndian.Uint16(rec[6:8])),
+					Parameter:    int(binary.BigEndian.Uint16(rec[8:10])),
+					Compression:  int(rec[10]),
+				}
+			}
+		case HeaderStructureRecord:
+			h.HeaderStructure = string(rec)
+		case HeaderRiceCompression:
+			if len(rec) >= 4 {
+				h.RiceCompression = &RiceCompressionHeader{
+					Flags:              binary.BigEndian.Uint16(rec[0:2]),
+					PixelsPerBlock:     int(rec[2]),
+					ScanLinesPerPacket: int(rec[3]),
+				}
+			}
+		}
+		pos += hlen
+	}
+
+	return &h, nil
+}
+
+// Compression returns the compression used for the data field of the file, preferring the NOAA
+// specific header over the image structure header since the former also covers non-image files
+func (h *Headers) Compression() int {
+	if h.NOAASpecific != nil {
+		return h.NOAASpecific.Compression
+	}
+	if h.ImageStructure != nil {
+		return h.ImageStructure.Compression
+	}
+	return CompressionNone
+}
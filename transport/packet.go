@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"encoding/binary"
+)
+
+// Space packet sequence flags
+const (
+	SequenceContinuation = 0
+	SequenceFirst        = 1
+	SequenceLast         = 2
+	SequenceStandalone   = 3
+)
+
+// APID used by fill packets, which carry no data
+const FillAPID = 2047
+
+const packetHeaderSize = 6
+
+// Packet is a single CCSDS space packet (CP_PDU) pulled out of a virtual channel's packet zone
+type Packet struct {
+	APID          int
+	SequenceFlag  int
+	SequenceCount int
+	// Data is the packet's user data, without the trailing CRC
+	Data    []byte
+	CRCGood bool
+}
+
+func parsePacket(raw []byte) Packet {
+	p := Packet{
+		APID:          int(binary.BigEndian.Uint16(raw[0:2]) & 0x07FF),
+		SequenceFlag:  int(raw[2] >> 6),
+		SequenceCount: int(binary.BigEndian.Uint16(raw[2:4]) & 0x3FFF),
+	}
+
+	body := raw[packetHeaderSize:]
+	if len(body) < 2 {
+		p.Data = body
+		return p
+	}
+
+	p.Data = body[:len(body)-2]
+	p.CRCGood = crc16(p.Data) == binary.BigEndian.Uint16(body[len(body)-2:])
+	return p
+}
+
+// packetLength returns the full size of a packet, including its primary header
+func packetLength(header []byte) int {
+	return packetHeaderSize + int(binary.BigEndian.Uint16(header[4:6])) + 1
+}
+
+// crc16 is the CRC-16/CCITT-FALSE checksum NOAA appends to every HRIT packet
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// spacePacket builds a space packet with the CRC NOAA puts on the end of its user data
+func spacePacket(apid, flag, count int, data []byte) []byte {
+	p := make([]byte, packetHeaderSize, packetHeaderSize+len(data)+2)
+	binary.BigEndian.PutUint16(p[0:2], uint16(apid))
+	binary.BigEndian.PutUint16(p[2:4], uint16(flag<<14|count))
+	binary.BigEndian.PutUint16(p[4:6], uint16(len(data)+2-1))
+	p = append(p, data...)
+	return binary.BigEndian.AppendUint16(p, crc16(data))
+}
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint16
+	}{
+		{nil, 0xFFFF},
+		{[]byte("123456789"), 0x29B1},
+		{[]byte{0x00}, 0xE1F0},
+		{[]byte{0xFF, 0xFF}, 0x0000},
+	}
+	for _, tt := range tests {
+		if got := crc16(tt.data); got != tt.want {
+			t.Errorf("crc16(%q) = %#04x, want %#04x", tt.data, got, tt.want)
+		}
+		// Running the CRC over the data and its CRC always leaves nothing
+		if got := crc16(binary.BigEndian.AppendUint16(tt.data, tt.want)); got != 0 {
+			t.Errorf("crc16(%q + CRC) = %#04x, want 0", tt.data, got)
+		}
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	data := []byte("some user data")
+	raw := spacePacket(1234, SequenceLast, 0x3FFE, data)
+
+	if got := packetLength(raw); got != len(raw) {
+		t.Errorf("packetLength() = %d, want %d", got, len(raw))
+	}
+	p := parsePacket(raw)
+	if p.APID != 1234 || p.SequenceFlag != SequenceLast || p.SequenceCount != 0x3FFE {
+		t.Errorf("parsePacket() = APID %d, flag %d, count %d, want 1234, %d, %d", p.APID, p.SequenceFlag, p.SequenceCount, SequenceLast, 0x3FFE)
+	}
+	if !bytes.Equal(p.Data, data) || !p.CRCGood {
+		t.Errorf("parsePacket() = %q, CRC good: %v, want %q with a good CRC", p.Data, p.CRCGood, data)
+	}
+
+	raw[packetHeaderSize+3] ^= 0x10
+	if p := parsePacket(raw); p.CRCGood {
+		t.Error("parsePacket() passed the CRC of a corrupted packet")
+	}
+}
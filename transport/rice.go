@@ -0,0 +1,251 @@
+package transport
+
+import (
+	"fmt"
+)
+
+// szip compatible "nearest neighbour" preprocessor flag found in the Rice compression secondary header
+const riceOptionNN = 32
+
+// Maximum number of blocks covered by a single zero block "remainder of segment" code
+const riceSegmentBlocks = 64
+
+// Low entropy zero block run length that signals "remainder of segment"
+const riceROS = 5
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (b *bitReader) read(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if b.pos >= len(b.data)*8 {
+			return 0, fmt.Errorf("Ran out of compressed data")
+		}
+		bit := (b.data[b.pos/8] >> (7 - uint(b.pos%8))) & 1
+		v = (v << 1) | uint32(bit)
+		b.pos++
+	}
+	return v, nil
+}
+
+// readFS reads a fundamental sequence: a run of zeros terminated by a one
+func (b *bitReader) readFS() (uint32, error) {
+	var count uint32
+	for {
+		bit, err := b.read(1)
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			return count, nil
+		}
+		count++
+	}
+}
+
+// align skips the padding after a reference sample interval, which always ends on a byte boundary
+func (b *bitReader) align() {
+	b.pos = (b.pos + 7) / 8 * 8
+}
+
+// RiceDecoder decompresses CCSDS 121.0 (Rice / szip) coded scan lines, as used by GOES-R HRIT to
+// compress the image data carried in each space packet. Only the subset of options NOAA uses is
+// supported: samples of up to 8 bits with an optional nearest neighbour preprocessor
+type RiceDecoder struct {
+	BitsPerPixel   int
+	PixelsPerBlock int
+	PixelsPerLine  int
+	Preprocess     bool
+	rsiBlocks      int
+	samples        []uint32
+}
+
+func NewRiceDecoder(hdr *RiceCompressionHeader, bitsPerPixel int, pixelsPerLine int) (*RiceDecoder, error) {
+	if bitsPerPixel < 1 || bitsPerPixel > 8 {
+		return nil, fmt.Errorf("Unsupported Rice sample size: %d bits", bitsPerPixel)
+	}
+	if hdr.PixelsPerBlock <= 0 || pixelsPerLine <= 0 {
+		return nil, fmt.Errorf("Invalid Rice parameters: %d pixels per block, %d pixels per line", hdr.PixelsPerBlock, pixelsPerLine)
+	}
+
+	r := RiceDecoder{
+		BitsPerPixel:   bitsPerPixel,
+		PixelsPerBlock: hdr.PixelsPerBlock,
+		PixelsPerLine:  pixelsPerLine,
+		Preprocess:     hdr.Flags&riceOptionNN != 0,
+		// Scan lines that are not a multiple of the block size are padded out to a full block
+		rsiBlocks: (pixelsPerLine + hdr.PixelsPerBlock - 1) / hdr.PixelsPerBlock,
+	}
+	r.samples = make([]uint32, r.rsiBlocks*r.PixelsPerBlock)
+	return &r, nil
+}
+
+// Decompress decodes up to `lines` scan lines from a single packet worth of compressed data, and
+// returns one byte per pixel
+func (r *RiceDecoder) Decompress(data []byte, lines int) ([]byte, error) {
+	br := bitReader{data: data}
+	out := make([]byte, 0, lines*r.PixelsPerLine)
+
+	for line := 0; line < lines; line++ {
+		if err := r.decodeRSI(&br); err != nil {
+			return out, fmt.Errorf("Line %d: %v", line, err)
+		}
+		r.postprocess()
+		for _, s := range r.samples[:r.PixelsPerLine] {
+			out = append(out, byte(s))
+		}
+	}
+	return out, nil
+}
+
+// decodeRSI decodes one reference sample interval, which szip sizes to exactly one scan line
+func (r *RiceDecoder) decodeRSI(br *bitReader) error {
+	n := r.BitsPerPixel
+	idLen := 3
+	uncompressedID := uint32(1<<idLen) - 1
+	ppb := r.PixelsPerBlock
+	pos := 0
+
+	clear(r.samples)
+
+	for block := 0; block < r.rsiBlocks; {
+		ref := 0
+		if r.Preprocess && block == 0 {
+			ref = 1
+		}
+
+		id, err := br.read(idLen)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case id == 0:
+			// Low entropy options
+			second, err := br.read(1)
+			if err != nil {
+				return err
+			}
+			if ref == 1 {
+				if r.samples[pos], err = br.read(n); err != nil {
+					return err
+				}
+				pos++
+			}
+
+			if second == 1 {
+				// Second extension: pairs of samples coded as a single fundamental sequence
+				for i := ref; i < ppb; {
+					m, err := br.readFS()
+					if err != nil {
+						return err
+					}
+					beta := uint32(0)
+					for (beta+1)*(beta+2)/2 <= m {
+						beta++
+					}
+					d1 := m - beta*(beta+1)/2
+					if i&1 == 0 {
+						r.samples[pos] = beta - d1
+						pos++
+						i++
+					}
+					r.samples[pos] = d1
+					pos++
+					i++
+				}
+				block++
+			} else {
+				// Zero block run
+				fs, err := br.readFS()
+				if err != nil {
+					return err
+				}
+				zeroBlocks := int(fs) + 1
+				if zeroBlocks == riceROS {
+					zeroBlocks = min(r.rsiBlocks-block, riceSegmentBlocks-(block%riceSegmentBlocks))
+				} else if zeroBlocks > riceROS {
+					zeroBlocks--
+				}
+				if block+zeroBlocks > r.rsiBlocks {
+					return fmt.Errorf("Zero block run overflows the scan line")
+				}
+				pos += zeroBlocks*ppb - ref
+				block += zeroBlocks
+			}
+		case id == uncompressedID:
+			for i := 0; i < ppb; i++ {
+				if r.samples[pos], err = br.read(n); err != nil {
+					return err
+				}
+				pos++
+			}
+			block++
+		default:
+			// Split sample option: fundamental sequences for the high bits, then k raw low bits
+			k := int(id) - 1
+			if ref == 1 {
+				if r.samples[pos], err = br.read(n); err != nil {
+					return err
+				}
+				pos++
+			}
+			for i := 0; i < ppb-ref; i++ {
+				fs, err := br.readFS()
+				if err != nil {
+					return err
+				}
+				r.samples[pos+i] = fs << k
+			}
+			if k > 0 {
+				for i := 0; i < ppb-ref; i++ {
+					low, err := br.read(k)
+					if err != nil {
+						return err
+					}
+					r.samples[pos+i] |= low
+				}
+			}
+			pos += ppb - ref
+			block++
+		}
+	}
+
+	// Each scan line is padded out to a whole byte, so the next one starts on a byte boundary
+	br.align()
+	return nil
+}
+
+// postprocess undoes the unit delay predictor and prediction error mapping
+func (r *RiceDecoder) postprocess() {
+	if !r.Preprocess {
+		return
+	}
+
+	xmax := uint32(1<<r.BitsPerPixel) - 1
+	med := xmax/2 + 1
+	last := r.samples[0]
+
+	for i := 1; i < len(r.samples); i++ {
+		d := r.samples[i]
+		half := (d >> 1) + (d & 1)
+		mask := uint32(0)
+		if last&med != 0 {
+			mask = xmax
+		}
+
+		if half <= mask^last {
+			if d&1 == 1 {
+				last -= half
+			} else {
+				last += half
+			}
+		} else {
+			last = mask ^ d
+		}
+		r.samples[i] = last & xmax
+	}
+}
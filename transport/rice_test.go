@@ -0,0 +1,270 @@
+package transport
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) write(v uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if (v>>uint(i))&1 == 1 {
+			w.data[w.n/8] |= 0x80 >> uint(w.n%8)
+		}
+		w.n++
+	}
+}
+
+func (w *bitWriter) writeFS(v uint32) {
+	for ; v > 0; v-- {
+		w.write(0, 1)
+	}
+	w.write(1, 1)
+}
+
+func (w *bitWriter) append(other *bitWriter) {
+	for i := 0; i < other.n; i++ {
+		w.write(uint32(other.data[i/8]>>(7-uint(i%8)))&1, 1)
+	}
+}
+
+func (w *bitWriter) align() {
+	w.n = len(w.data) * 8
+}
+
+// riceEncode is a minimal CCSDS 121.0 encoder to test the decoder against. It codes each block with
+// whichever option comes out shortest, and runs of zero blocks as such, so every option gets exercised
+func riceEncode(lines [][]byte, bitsPerPixel, pixelsPerBlock int, preprocess bool) []byte {
+	var w bitWriter
+	for _, line := range lines {
+		blocks := (len(line) + pixelsPerBlock - 1) / pixelsPerBlock
+		samples := make([]uint32, blocks*pixelsPerBlock)
+		for i := range samples {
+			// Pad the last block out with the last pixel
+			samples[i] = uint32(line[min(i, len(line)-1)])
+		}
+		if preprocess {
+			samples = riceMap(samples, bitsPerPixel)
+		}
+
+		for block := 0; block < blocks; {
+			ref := 0
+			if preprocess && block == 0 {
+				ref = 1
+			}
+			s := samples[block*pixelsPerBlock : (block+1)*pixelsPerBlock]
+
+			// Zero blocks run up to the end of the segment or the scan line, whichever comes first
+			run := 0
+			end := min(blocks, (block/riceSegmentBlocks+1)*riceSegmentBlocks)
+			for b := block; b < end; b++ {
+				// The reference sample doesn't have to be zero
+				start := b * pixelsPerBlock
+				if b == block {
+					start += ref
+				}
+				if !allZero(samples[start : (b+1)*pixelsPerBlock]) {
+					break
+				}
+				run++
+			}
+			if run > 0 {
+				w.write(0, 3)
+				w.write(0, 1)
+				if ref == 1 {
+					w.write(s[0], bitsPerPixel)
+				}
+				switch {
+				case block+run == end:
+					w.writeFS(riceROS - 1)
+				case run < riceROS:
+					w.writeFS(uint32(run - 1))
+				default:
+					w.writeFS(uint32(run))
+				}
+				block += run
+				continue
+			}
+
+			var best *bitWriter
+			for _, candidate := range riceOptions(s, ref, bitsPerPixel) {
+				if best == nil || candidate.n < best.n {
+					best = candidate
+				}
+			}
+			w.append(best)
+			block++
+		}
+		w.align()
+	}
+	return w.data
+}
+
+func allZero(samples []uint32) bool {
+	for _, s := range samples {
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// riceOptions codes a block with the second extension, every split sample option and no compression
+func riceOptions(s []uint32, ref, n int) []*bitWriter {
+	var options []*bitWriter
+
+	second := &bitWriter{}
+	second.write(0, 3)
+	second.write(1, 1)
+	if ref == 1 {
+		second.write(s[0], n)
+	}
+	for i := 0; i < len(s); i += 2 {
+		a, b := s[i], s[i+1]
+		if i == 0 && ref == 1 {
+			a = 0
+		}
+		second.writeFS((a+b)*(a+b+1)/2 + b)
+	}
+	options = append(options, second)
+
+	for k := 0; k <= 5 && k < n; k++ {
+		split := &bitWriter{}
+		split.write(uint32(k+1), 3)
+		if ref == 1 {
+			split.write(s[0], n)
+		}
+		for _, v := range s[ref:] {
+			split.writeFS(v >> uint(k))
+		}
+		if k > 0 {
+			for _, v := range s[ref:] {
+				split.write(v&(1<<uint(k)-1), k)
+			}
+		}
+		options = append(options, split)
+	}
+
+	raw := &bitWriter{}
+	raw.write(7, 3)
+	for _, v := range s {
+		raw.write(v, n)
+	}
+	return append(options, raw)
+}
+
+// riceMap runs the unit delay predictor and maps the prediction errors, leaving the first sample as the
+// reference
+func riceMap(samples []uint32, n int) []uint32 {
+	xmax := 1<<n - 1
+	mapped := make([]uint32, len(samples))
+	mapped[0] = samples[0]
+	for i := 1; i < len(samples); i++ {
+		prediction := int(samples[i-1])
+		delta := int(samples[i]) - prediction
+		theta := min(prediction, xmax-prediction)
+		switch {
+		case delta >= 0 && delta <= theta:
+			mapped[i] = uint32(2 * delta)
+		case delta < 0 && -delta <= theta:
+			mapped[i] = uint32(-2*delta - 1)
+		default:
+			mapped[i] = uint32(theta + max(delta, -delta))
+		}
+	}
+	return mapped
+}
+
+func TestRiceDecodeKnown(t *testing.T) {
+	// Two scan lines of 0 1 2 3 0 0 1 0, each coded as a single fundamental sequence block (ID 001),
+	// then padded to a byte
+	data := []byte{0x34, 0x8E, 0xC0, 0x34, 0x8E, 0xC0}
+	r, err := NewRiceDecoder(&RiceCompressionHeader{PixelsPerBlock: 8}, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Decompress(data, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 1, 2, 3, 0, 0, 1, 0, 0, 1, 2, 3, 0, 0, 1, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Decompress() = %v, want %v", got, want)
+	}
+}
+
+func TestRiceRoundTrip(t *testing.T) {
+	tests := []struct {
+		name           string
+		bitsPerPixel   int
+		pixelsPerBlock int
+		columns        int
+		lines          int
+		preprocess     bool
+		pixel          func(rng *rand.Rand, x, y int) int
+	}{
+		{"flat", 8, 16, 200, 3, true, func(_ *rand.Rand, _, _ int) int { return 42 }},
+		{"mostly flat", 8, 16, 2000, 2, true, func(_ *rand.Rand, x, _ int) int {
+			if x > 1500 && x < 1520 {
+				return 200
+			}
+			return 10
+		}},
+		{"gradient", 8, 16, 300, 4, true, func(_ *rand.Rand, x, y int) int { return (x + 3*y) / 2 % 256 }},
+		{"low noise", 8, 16, 256, 3, true, func(rng *rand.Rand, _, _ int) int { return 128 + rng.IntN(3) - 1 }},
+		{"noise", 8, 16, 256, 3, true, func(rng *rand.Rand, _, _ int) int { return rng.IntN(256) }},
+		{"edges of the range", 8, 16, 256, 2, true, func(rng *rand.Rand, x, _ int) int { return []int{0, 255, 1, 254}[rng.IntN(4)] }},
+		{"no preprocessor", 8, 16, 256, 3, false, func(rng *rand.Rand, _, _ int) int { return rng.IntN(20) }},
+		{"partial last block", 8, 16, 250, 3, true, func(rng *rand.Rand, x, _ int) int { return x/4 + rng.IntN(4) }},
+		{"4 bit samples", 4, 8, 101, 5, true, func(rng *rand.Rand, _, _ int) int { return rng.IntN(16) }},
+		{"odd block size", 8, 10, 95, 3, true, func(rng *rand.Rand, x, _ int) int { return x + rng.IntN(2) }},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(26, uint64(i)))
+			lines := make([][]byte, tt.lines)
+			var want []byte
+			for y := range lines {
+				lines[y] = make([]byte, tt.columns)
+				for x := range lines[y] {
+					lines[y][x] = byte(tt.pixel(rng, x, y))
+				}
+				want = append(want, lines[y]...)
+			}
+
+			flags := uint16(0)
+			if tt.preprocess {
+				flags = riceOptionNN
+			}
+			r, err := NewRiceDecoder(&RiceCompressionHeader{Flags: flags, PixelsPerBlock: tt.pixelsPerBlock}, tt.bitsPerPixel, tt.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Decompress(riceEncode(lines, tt.bitsPerPixel, tt.pixelsPerBlock, tt.preprocess), tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Decompress() didn't round trip\ngot  %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRiceTruncated(t *testing.T) {
+	r, err := NewRiceDecoder(&RiceCompressionHeader{PixelsPerBlock: 8}, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Decompress([]byte{0x34, 0x8E, 0xC0, 0x34}, 2); err == nil {
+		t.Error("Decompress() ran off the end of the data without an error")
+	}
+}
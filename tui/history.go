@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Used when tui.history_minutes isn't set
+const defaultHistoryMinutes = 5
+
+// historySample is one reading of the signal stats, taken every refresh
+type historySample struct {
+	Time      time.Time
+	SNR       float64
+	Quality   float64
+	BER       float64
+	FrameLock bool
+}
+
+// history keeps the signal stats over the last few minutes, for the sparklines
+type history struct {
+	span    time.Duration
+	mutex   sync.Mutex
+	samples []historySample
+}
+
+func newHistory(minutes int) *history {
+	if minutes <= 0 {
+		minutes = defaultHistoryMinutes
+	}
+	return &history{span: time.Duration(minutes) * time.Minute}
+}
+
+func (h *history) add(sample historySample) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.samples = append(h.samples, sample)
+	expired := 0
+	for expired < len(h.samples) && sample.Time.Sub(h.samples[expired].Time) > h.span {
+		expired++
+	}
+	h.samples = h.samples[expired:]
+}
+
+// historyColumn is what's drawn in one column of a sparkline
+type historyColumn struct {
+	// Value is the mean of the samples that fall in the column, leaving out the ones with no value (NaN,
+	// e.g. no SNR estimate); there may not be any
+	Value   float64
+	Samples int
+	// LockChange is 1 if frame lock was gained in this column, -1 if it was lost, and 0 if neither
+	LockChange int
+}
+
+// columns splits the history into width columns, newest on the right, and works out the value of each
+func (h *history) columns(width int, value func(historySample) float64) []historyColumn {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	columns := make([]historyColumn, width)
+	start := time.Now().Add(-h.span)
+	for i, sample := range h.samples {
+		col := int(float64(sample.Time.Sub(start)) / float64(h.span) * float64(width))
+		if col < 0 || col >= width {
+			continue
+		}
+		if v := value(sample); !math.IsNaN(v) {
+			columns[col].Value += v
+			columns[col].Samples++
+		}
+		if i > 0 && sample.FrameLock != h.samples[i-1].FrameLock {
+			if sample.FrameLock {
+				columns[col].LockChange = 1
+			} else {
+				columns[col].LockChange = -1
+			}
+		}
+	}
+	for i := range columns {
+		if columns[i].Samples > 0 {
+			columns[i].Value /= float64(columns[i].Samples)
+		}
+	}
+	return columns
+}
+
+// sparkline plots one of the history's stats as a bar per column. Columns where frame lock was gained
+// or lost are marked along the bottom with a green ▲ or a red ▼
+type sparkline struct {
+	*tview.Box
+	history *history
+	label   string
+	unit    string
+	value   func(historySample) float64
+	// The range of the plot. If max isn't above min, it's scaled to fit the data instead
+	min, max float64
+	color    tcell.Color
+}
+
+var sparkBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+func newSparkline(h *history, label string, unit string, value func(historySample) float64, min float64, max float64) *sparkline {
+	return &sparkline{
+		Box:     tview.NewBox(),
+		history: h,
+		label:   label,
+		unit:    unit,
+		value:   value,
+		min:     min,
+		max:     max,
+		color:   tcell.ColorLightSkyBlue,
+	}
+}
+
+func (s *sparkline) Draw(screen tcell.Screen) {
+	s.Box.DrawForSubclass(screen, s)
+	x, y, width, height := s.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	columns := s.history.columns(width, s.value)
+	lo, hi := s.min, s.max
+	if hi <= lo {
+		lo, hi = math.Inf(1), math.Inf(-1)
+		for _, col := range columns {
+			if col.Samples > 0 {
+				lo, hi = min(lo, col.Value), max(hi, col.Value)
+			}
+		}
+		if math.IsInf(lo, 0) {
+			lo, hi = 0, 1
+		} else if hi <= lo {
+			hi = lo + 1
+		}
+	}
+
+	style := tcell.StyleDefault.Foreground(s.color)
+	var last historyColumn
+	for i, col := range columns {
+		if col.Samples > 0 {
+			last = col
+			eighths := int(math.Round(clamp((col.Value-lo)/(hi-lo), 0, 1) * float64(height*8)))
+			for row := 0; row < height; row++ {
+				fill := min(max(eighths-row*8, 0), 8)
+				screen.SetContent(x+i, y+height-1-row, sparkBlocks[fill], nil, style)
+			}
+		}
+		switch col.LockChange {
+		case 1:
+			screen.SetContent(x+i, y+height-1, '▲', nil, tcell.StyleDefault.Foreground(tcell.ColorGreen))
+		case -1:
+			screen.SetContent(x+i, y+height-1, '▼', nil, tcell.StyleDefault.Foreground(tcell.ColorRed))
+		}
+	}
+
+	current := "-"
+	if last.Samples > 0 {
+		current = fmt.Sprintf("%.2f%s", last.Value, s.unit)
+	}
+	label := fmt.Sprintf("%s: %s [gray](%.1f to %.1f)", s.label, current, lo, hi)
+	tview.Print(screen, label, x, y, width, tview.AlignLeft, tcell.ColorWhite)
+}
+
+func clamp(v float64, lo float64, hi float64) float64 {
+	return min(max(v, lo), hi)
+}
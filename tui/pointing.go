@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jrwynneiii/goestuner/demod"
+)
+
+// The pointing metrics tui.pointing_metric can select
+const (
+	PointingSNR      = "snr"
+	PointingQuality  = "quality"
+	PointingCombined = "combined"
+)
+
+// How far back the pointing view looks to tell whether things are getting better or worse
+const pointingRecent = 3 * time.Second
+
+// pointing tracks the figure the dish is pointed by: its session peak, and how the last few seconds
+// compare with it and with the few seconds before
+type pointing struct {
+	metric string
+	mutex  sync.Mutex
+	// The session peak of the quality and combined metrics. The SNR's is the demodulator's PeakHold, so
+	// there's only the one SNR peak to keep track of
+	peak     float64
+	peakTime time.Time
+	// Readings from the last 2*pointingRecent
+	samples []historySample
+}
+
+func newPointing(metric string) *pointing {
+	switch metric {
+	case PointingSNR, PointingQuality, PointingCombined:
+	case "":
+		metric = PointingSNR
+	default:
+		log.Fatalf("Unknown pointing metric %q; expected \"snr\", \"quality\" or \"combined\"", metric)
+	}
+	return &pointing{metric: metric}
+}
+
+// value works out the pointing figure from a reading, which is NaN if there's no SNR estimate to go on.
+// The combined figure scores the SNR from 0 at 0 dB (or no estimate) to 100 at 20 dB, and averages that
+// with the signal quality, so it still moves before frame lock
+func (p *pointing) value(sample historySample) float64 {
+	switch p.metric {
+	case PointingQuality:
+		return sample.Quality
+	case PointingCombined:
+		score := 0.0
+		if !math.IsNaN(sample.SNR) {
+			score = clamp(sample.SNR*5, 0, 100)
+		}
+		return (score + sample.Quality) / 2
+	default:
+		return sample.SNR
+	}
+}
+
+func (p *pointing) unit() string {
+	switch p.metric {
+	case PointingQuality:
+		return "%"
+	case PointingCombined:
+		return "/100"
+	default:
+		return " dB"
+	}
+}
+
+func (p *pointing) add(sample historySample) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if v := p.value(sample); p.metric != PointingSNR && !math.IsNaN(v) && (p.peakTime.IsZero() || v > p.peak) {
+		p.peak = v
+		p.peakTime = sample.Time
+	}
+	p.samples = append(p.samples, sample)
+	expired := 0
+	for expired < len(p.samples) && sample.Time.Sub(p.samples[expired].Time) > 2*pointingRecent {
+		expired++
+	}
+	p.samples = p.samples[expired:]
+}
+
+// reset starts the quality or combined peak over, e.g. before sweeping the dish again. The SNR peak is the
+// demodulator's, so this has to go along with anything that resets that one: ResetPeakSNR, and the
+// demodulator's Reset in a flush
+func (p *pointing) reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.peak = 0
+	p.peakTime = time.Time{}
+}
+
+// pointingReading is what the pointing view shows
+type pointingReading struct {
+	// The mean over the last pointingRecent, and over the pointingRecent before that, of the readings
+	// that have a value. Recent is NaN if none of them do
+	Recent, Previous float64
+	Peak             float64
+	PeakTime         time.Time
+	FrameLock        bool
+	Quality          float64
+}
+
+// reading summarises the last few seconds. snrPeak is the demodulator's SNR peak, which is the peak when
+// pointing by SNR
+func (p *pointing) reading(snrPeak demod.PeakHold) (pointingReading, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.samples) == 0 {
+		return pointingReading{}, false
+	}
+	last := p.samples[len(p.samples)-1]
+	reading := pointingReading{Peak: p.peak, PeakTime: p.peakTime, FrameLock: last.FrameLock, Quality: last.Quality}
+	if p.metric == PointingSNR {
+		reading.Peak, reading.PeakTime = snrPeak.Peak, snrPeak.Time
+	}
+	var recent, previous int
+	for _, sample := range p.samples {
+		v := p.value(sample)
+		switch {
+		case math.IsNaN(v):
+		case last.Time.Sub(sample.Time) <= pointingRecent:
+			reading.Recent += v
+			recent++
+		default:
+			reading.Previous += v
+			previous++
+		}
+	}
+	// With no recent readings, this is NaN
+	reading.Recent /= float64(recent)
+	if previous > 0 {
+		reading.Previous /= float64(previous)
+	} else {
+		reading.Previous = reading.Recent
+	}
+	return reading, true
+}
+
+// A big block font for the pointing figure, five rows high
+var bigGlyphs = map[rune][5]string{
+	'0': {"███", "█ █", "█ █", "█ █", "███"},
+	'1': {" █ ", "██ ", " █ ", " █ ", "███"},
+	'2': {"███", "  █", "███", "█  ", "███"},
+	'3': {"███", "  █", "███", "  █", "███"},
+	'4': {"█ █", "█ █", "███", "  █", "  █"},
+	'5': {"███", "█  ", "███", "  █", "███"},
+	'6': {"███", "█  ", "███", "█ █", "███"},
+	'7': {"███", "  █", "  █", "  █", "  █"},
+	'8': {"███", "█ █", "███", "█ █", "███"},
+	'9': {"███", "█ █", "███", "  █", "███"},
+	'-': {"   ", "   ", "███", "   ", "   "},
+	'.': {" ", " ", " ", " ", "█"},
+}
+
+// bigText renders text in the big font, with every block doubled up so it can be read from the dish
+func bigText(text string) string {
+	var rows [10]strings.Builder
+	for _, r := range text {
+		glyph, ok := bigGlyphs[r]
+		if !ok {
+			continue
+		}
+		for i, line := range glyph {
+			doubled := strings.NewReplacer("█", "██", " ", "  ").Replace(line) + "  "
+			rows[2*i].WriteString(doubled)
+			rows[2*i+1].WriteString(doubled)
+		}
+	}
+	lines := make([]string, len(rows))
+	for i := range rows {
+		lines[i] = rows[i].String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPointing renders the pointing view: the figure itself in the big font, coloured by how close it
+// is to the peak, the peak and when it was seen, and whether the dish is getting hotter or colder
+func formatPointing(p *pointing, stats *demod.DemodulatorStats) string {
+	estimator := stats.SNREstimator
+	reading, ok := p.reading(stats.PeakSNR)
+	if !ok {
+		return "Waiting for the demodulator..."
+	}
+	name := map[string]string{
+		PointingSNR:      fmt.Sprintf("SNR in dB (Es/N0, %s)", estimator),
+		PointingQuality:  "Signal Quality in %",
+		PointingCombined: "Combined SNR and Signal Quality, out of 100",
+	}[p.metric]
+	lock := "[red]no[-]"
+	if reading.FrameLock {
+		lock = "[green]yes[-]"
+	}
+	status := fmt.Sprintf("\nFrame lock: %s    Signal quality: %.0f%%\n", lock, reading.Quality)
+
+	if math.IsNaN(reading.Recent) {
+		// The SNR estimator has had nothing to go on for the last few seconds
+		return fmt.Sprintf("\n[red]%s[-]\n\n[white::b]%s[-::-]\n\nNo SNR estimate; the signal is too weak for the %s estimator\n", bigText("-"), name, estimator) + status
+	}
+
+	// How far off the peak counts as being at it, and as being way off, in the metric's units
+	near, far := 0.5, 3.0
+	if p.metric != PointingSNR {
+		near, far = 2, 15
+	}
+	below := reading.Peak - reading.Recent
+	color := "green"
+	if below > far {
+		color = "red"
+	} else if below > near {
+		color = "yellow"
+	}
+
+	text := fmt.Sprintf("\n[%s]%s[-]\n\n[white::b]%s[-::-]\n\n", color, bigText(fmt.Sprintf("%.1f", reading.Recent)), name)
+
+	if reading.PeakTime.IsZero() {
+		// Just reset
+		return text + "[lightskyblue]Peak:[-] -\n" + status
+	}
+	text += fmt.Sprintf("[lightskyblue]Peak:[-] %.2f%s at %s (%ds ago)\n", reading.Peak, p.unit(), reading.PeakTime.Format("15:04:05"), int(time.Since(reading.PeakTime).Seconds()))
+	if below <= near {
+		text += "[green]● AT PEAK[-]"
+	} else {
+		text += fmt.Sprintf("[%s]%.2f%s below peak[-]", color, below, p.unit())
+	}
+
+	trend := reading.Recent - reading.Previous
+	switch {
+	case trend > near/2:
+		text += fmt.Sprintf("    [green]▲ HOTTER (%+.2f%s)[-]\n", trend, p.unit())
+	case trend < -near/2:
+		text += fmt.Sprintf("    [red]▼ COLDER (%+.2f%s)[-]\n", trend, p.unit())
+	default:
+		text += "    [white]■ STEADY[-]\n"
+	}
+
+	return text + status
+}
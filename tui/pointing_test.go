@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrwynneiii/goestuner/demod"
+)
+
+// An arbitrary time for the readings in tests to start from
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testPointing feeds a pointing tracker a second of readings at previous, just over pointingRecent ago,
+// then a second at recent. Both the SNR and quality are set to the reading
+func testPointing(metric string, previous, recent float64) *pointing {
+	p := newPointing(metric)
+	for i := 0; i < 10; i++ {
+		at := testStart.Add(time.Duration(i) * 100 * time.Millisecond)
+		p.add(historySample{Time: at, SNR: previous, Quality: previous})
+	}
+	for i := 0; i < 10; i++ {
+		at := testStart.Add(pointingRecent + time.Second + time.Duration(i)*100*time.Millisecond)
+		p.add(historySample{Time: at, SNR: recent, Quality: recent, FrameLock: true})
+	}
+	return p
+}
+
+func TestPointingReading(t *testing.T) {
+	snrPeak := demod.PeakHold{Peak: 12, Time: testStart.Add(-time.Minute), Held: 11}
+	tests := []struct {
+		name             string
+		metric           string
+		previous, recent float64
+		wantPrevious     float64
+		wantRecent       float64
+		wantPeak         float64
+		wantPeakTime     time.Time
+	}{
+		// The SNR peak is the demodulator's, not the highest reading the view has seen
+		{"snr", PointingSNR, 8, 9, 8, 9, 12, testStart.Add(-time.Minute)},
+		{"quality", PointingQuality, 80, 70, 80, 70, 80, testStart},
+		{"combined", PointingCombined, 10, 20, 30, 60, 60, testStart.Add(pointingRecent + time.Second)},
+		// Readings without an SNR estimate are left out
+		{"no previous estimate", PointingSNR, math.NaN(), 9, 9, 9, 12, testStart.Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading, ok := testPointing(tt.metric, tt.previous, tt.recent).reading(snrPeak)
+			if !ok {
+				t.Fatal("no reading")
+			}
+			if reading.Previous != tt.wantPrevious || reading.Recent != tt.wantRecent {
+				t.Errorf("previous %.2f, recent %.2f, want %.2f, %.2f", reading.Previous, reading.Recent, tt.wantPrevious, tt.wantRecent)
+			}
+			if reading.Peak != tt.wantPeak || !reading.PeakTime.Equal(tt.wantPeakTime) {
+				t.Errorf("peak %.2f at %v, want %.2f at %v", reading.Peak, reading.PeakTime, tt.wantPeak, tt.wantPeakTime)
+			}
+			if !reading.FrameLock || reading.Quality != tt.recent {
+				t.Errorf("frame lock %v, quality %.0f, want the latest reading's", reading.FrameLock, reading.Quality)
+			}
+		})
+	}
+
+	if _, ok := newPointing(PointingSNR).reading(snrPeak); ok {
+		t.Error("got a reading without any readings")
+	}
+}
+
+func TestPointingReset(t *testing.T) {
+	p := testPointing(PointingQuality, 80, 70)
+	p.reset()
+	p.add(historySample{Time: testStart.Add(time.Minute), Quality: 60})
+	reading, _ := p.reading(demod.PeakHold{})
+	if reading.Peak != 60 || !reading.PeakTime.Equal(testStart.Add(time.Minute)) {
+		t.Errorf("peak %.2f at %v after a reset, want the next reading", reading.Peak, reading.PeakTime)
+	}
+}
+
+func TestFormatPointing(t *testing.T) {
+	tests := []struct {
+		name             string
+		metric           string
+		previous, recent float64
+		peak             float64
+		// The colour of the big figure, and what the view should say
+		wantColor string
+		want      []string
+	}{
+		{"snr at the peak", PointingSNR, 10, 10, 10, "green", []string{"● AT PEAK", "■ STEADY"}},
+		{"snr just off the peak", PointingSNR, 10, 10, 10.5, "green", []string{"● AT PEAK"}},
+		{"snr near the peak", PointingSNR, 10, 10, 11, "yellow", []string{"1.00 dB below peak"}},
+		{"snr well off the peak", PointingSNR, 10, 10, 13.5, "red", []string{"3.50 dB below peak"}},
+		{"snr at the edge of near", PointingSNR, 10, 10, 13, "yellow", []string{"3.00 dB below peak"}},
+		{"snr hotter", PointingSNR, 8, 9, 10, "yellow", []string{"▲ HOTTER (+1.00 dB)"}},
+		{"snr colder", PointingSNR, 9, 8.5, 10, "yellow", []string{"▼ COLDER (-0.50 dB)"}},
+		{"snr barely moving", PointingSNR, 9, 9.2, 10, "yellow", []string{"■ STEADY"}},
+		{"quality near the peak", PointingQuality, 80, 70, 0, "yellow", []string{"10.00% below peak", "▼ COLDER (-10.00%)"}},
+		{"quality well off the peak", PointingQuality, 90, 70, 0, "red", []string{"20.00% below peak"}},
+		{"quality barely moving", PointingQuality, 70, 70.8, 0, "green", []string{"● AT PEAK", "■ STEADY"}},
+		{"quality hotter", PointingQuality, 70, 72, 0, "green", []string{"● AT PEAK", "▲ HOTTER (+2.00%)"}},
+		{"no snr estimate", PointingSNR, 10, math.NaN(), 10, "red", []string{"No SNR estimate; the signal is too weak for the m2m4 estimator", "Frame lock: [green]yes[-]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &demod.DemodulatorStats{
+				SNREstimator: "m2m4",
+				PeakSNR:      demod.PeakHold{Peak: tt.peak, Time: testStart},
+			}
+			text := formatPointing(testPointing(tt.metric, tt.previous, tt.recent), stats)
+			if !strings.HasPrefix(text, "\n["+tt.wantColor+"]") {
+				t.Errorf("the figure isn't %s:\n%s", tt.wantColor, text)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q isn't in:\n%s", want, text)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatPointingAfterReset(t *testing.T) {
+	// The demodulator's peak has just been reset
+	text := formatPointing(testPointing(PointingSNR, 10, 10), &demod.DemodulatorStats{SNREstimator: "m2m4"})
+	if !strings.Contains(text, "Peak:[-] -\n") || strings.Contains(text, "below peak") {
+		t.Errorf("a reset peak should show as -:\n%s", text)
+	}
+
+	if text := formatPointing(newPointing(PointingSNR), &demod.DemodulatorStats{}); text != "Waiting for the demodulator..." {
+		t.Errorf("got %q without any readings", text)
+	}
+}
@@ -1,15 +1,22 @@
 package tui
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gdamore/tcell/v2"
+	"github.com/jrwynneiii/goestuner/antenna"
 	"github.com/jrwynneiii/goestuner/config"
 	"github.com/jrwynneiii/goestuner/datalink"
 	"github.com/jrwynneiii/goestuner/demod"
+	"github.com/jrwynneiii/goestuner/pipeline"
+	"github.com/jrwynneiii/goestuner/products"
+	"github.com/jrwynneiii/goestuner/queue"
 	"github.com/jrwynneiii/goestuner/radio"
 	"github.com/navidys/tvxwidgets"
 	"github.com/rivo/tview"
@@ -19,7 +26,7 @@ import (
 var LogOut *tview.TextView
 var DebugOut *tview.TextView
 
-func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio.Radio[complex64], enableFFT bool, tuiConf config.TuiConf) {
+func StartUI(ctx context.Context, decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio.Radio[complex64], processor *products.Processor, enableFFT bool, tuiConf config.TuiConf, lookAngles []antenna.LookAngle, rotator *antenna.Rotator) {
 	enableDebugOutput := false
 	debugVisible := false
 	pause := false
@@ -64,7 +71,8 @@ func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio
 	lockData := &LockTableData{}
 	channelStats := tview.NewTable().SetContent(channelData)
 	lockTable := tview.NewTable().SetContent(lockData)
-	channelStats.SetSelectable(false, false).SetBorder(true).SetTitle("Per-Channel Stats")
+	UpdateChannels(nil)
+	channelStats.SetSelectable(false, false).SetBorder(true).SetTitle(channelStatsTitle(channelSort))
 	lockTable.SetSelectable(false, false).SetBorder(false)
 
 	// Init the FFT plot
@@ -99,13 +107,24 @@ func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio
 	rsCorrectionsGauge.SetEmptyColor(tcell.ColorBlack)
 	rsCorrectionsGauge.SetBorder(false)
 
+	// The trend over the last few minutes sits next to the gauges, to see which way things are going while
+	// moving the dish
+	signalHistory := newHistory(tuiConf.HistoryMinutes)
+	historyBox := tview.NewFlex().SetDirection(tview.FlexRow)
+	historyBox.AddItem(newSparkline(signalHistory, "SNR", " dB", func(s historySample) float64 { return s.SNR }, 0, 0), 0, 1, false)
+	historyBox.AddItem(newSparkline(signalHistory, "Signal Quality", "%", func(s historySample) float64 { return s.Quality }, 0, 100), 0, 1, false)
+	historyBox.AddItem(newSparkline(signalHistory, "Viterbi Error Rate", "%", func(s historySample) float64 { return s.BER }, 0, 100), 0, 1, false)
+
 	// Init our decoder stats flex rows
-	gaugeBox := tview.NewFlex()
-	gaugeBox.SetDirection(tview.FlexRow)
-	gaugeBox.AddItem(signalGauge, 0, 1, false)
-	gaugeBox.AddItem(berGauge, 0, 1, false)
-	gaugeBox.AddItem(rsCorrectionsGauge, 0, 1, false)
-	gaugeBox.SetTitle("Signal Stats")
+	gauges := tview.NewFlex()
+	gauges.SetDirection(tview.FlexRow)
+	gauges.AddItem(signalGauge, 0, 1, false)
+	gauges.AddItem(berGauge, 0, 1, false)
+	gauges.AddItem(rsCorrectionsGauge, 0, 1, false)
+	gaugeBox := tview.NewFlex().SetDirection(tview.FlexColumn)
+	gaugeBox.AddItem(gauges, 0, 1, false)
+	gaugeBox.AddItem(historyBox, 0, 1, false)
+	gaugeBox.SetTitle(fmt.Sprintf("Signal Stats (last %d minutes)", int(signalHistory.span.Minutes())))
 	gaugeBox.SetBorder(true)
 
 	decoderStats := tview.NewFlex().SetDirection(tview.FlexRow)
@@ -136,52 +155,86 @@ func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio
 	page.AddItem(leftCol, 0, 2, false)
 	page.AddItem(rightCol, 0, 5, false)
 
+	// Admin messages get their own page, since they can be quite long
+	adminView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetScrollable(true)
+	adminView.SetBorder(true).SetTitle("Admin Messages (press 'a' to return)")
+
+	// So do the pipeline's stats, which are only needed while tuning it
+	symbolsView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(false)
+	symbolsView.SetBorder(true).SetTitle("Pipeline Stats (press 'y' to return)")
+
+	// The pointing view has a single big figure to watch while moving the dish
+	dishPointing := newPointing(tuiConf.PointingMetric)
+	pointingView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter)
+	pointingView.SetBorder(true).SetTitle("Dish Pointing (press 'o' to return, 'r' to reset the peak)")
+
+	// The look angles, if the station's location is set, and the rotator's position go across the top of
+	// the main page
+	mainPage := tview.NewFlex().SetDirection(tview.FlexRow)
+	header := tview.NewTextView().SetDynamicColors(true)
+	if len(lookAngles) > 0 || rotator != nil {
+		header.SetText(formatHeader(lookAngles, rotator))
+		mainPage.AddItem(header, 1, 0, false)
+	}
+	mainPage.AddItem(page, 0, 1, false)
+
+	pages := tview.NewPages()
+	pages.AddPage("main", mainPage, true, true)
+	pages.AddPage("admin", adminView, true, false)
+	pages.AddPage("symbols", symbolsView, true, false)
+	pages.AddPage("pointing", pointingView, true, false)
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// The arrow keys jog the rotator, if there is one, on the pages without anything to scroll
+		if front, _ := pages.GetFrontPage(); rotator != nil && (front == "main" || front == "pointing") {
+			azimuth, elevation := 0, 0
+			switch event.Key() {
+			case tcell.KeyLeft:
+				azimuth = -1
+			case tcell.KeyRight:
+				azimuth = 1
+			case tcell.KeyUp:
+				elevation = 1
+			case tcell.KeyDown:
+				elevation = -1
+			}
+			if azimuth != 0 || elevation != 0 {
+				// Don't hold up the TUI waiting on rotctld. Jog takes the presses one at a time
+				go func() {
+					if err := rotator.Jog(azimuth, elevation); err != nil {
+						log.Errorf("Could not jog the rotator: %v", err)
+					}
+				}()
+				return nil
+			}
+		}
+
 		switch event.Rune() {
 		case 'q':
 			app.Stop()
 		case 'f':
-			//Pause radio
 			app.Suspend(func() {
-				// Reset to stdout log output and Pause the radio
+				// Log to stdout while the TUI is suspended
 				log.SetOutput(os.Stdout)
-				log.Debugf("Pausing SDR")
-				r.Pause()
-				//Wait for physical layer to drain
-				log.Debug("Waiting for phyiscal layer to drain")
-				for len(demodulator.SampleInput) > 0 {
-					time.Sleep(50 * time.Millisecond)
-				}
-				//Forcibly flush the datalink layer. This sucks but it is what it is
-				log.Debug("Flushing datalink layer")
-				for len(*demodulator.SymbolsOutput) > 0 {
-					select {
-					case c := <-*demodulator.SymbolsOutput:
-						func(a any) {}(c)
-					}
-				}
-				//Reset stats
-				log.Debug("Resetting channel and decoder stats")
+				pipeline.Flush(r, demodulator, decoder)
+				// The flush started the demodulator's SNR peak over, so start the other pointing peaks over too
+				dishPointing.reset()
 				ResetChannelAndDecoderStats()
-
-				//Reset datalink layer
-				log.Debug("Resetting Lock and guage stats")
-				decoder.FrameLock = false
-				decoder.SigQuality = 0.0
-				decoder.AverageRsCorrections = 0
-				decoder.RxPacketsPerChannel = make(map[int]int)
-				decoder.DroppedPacketsPerChannel = make(map[int]int)
-				decoder.TotalFramesProcessed = 0
-				signalGauge.SetValue(float64(decoder.SigQuality))
+				signalGauge.SetValue(0.0)
 				berGauge.SetValue(0.0)
-				rsCorrectionsGauge.SetValue(float64(decoder.AverageRsCorrections))
-
-				//Restart radio
-				log.Debug("Reconnecting to SDR...")
-				r.Connect()
-				log.Debug("Flushed!")
+				rsCorrectionsGauge.SetValue(0.0)
 				log.SetOutput(LogOut)
 			})
+		case 'r':
+			demodulator.ResetPeakSNR()
+			dishPointing.reset()
 		case 'd':
 			if !enableDebugOutput {
 				enableDebugOutput = true
@@ -194,59 +247,97 @@ func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio
 			} else {
 				pause = true
 			}
+		case 's':
+			channelStats.SetTitle(channelStatsTitle(CycleChannelSort()))
+		case 'a':
+			if front, _ := pages.GetFrontPage(); front == "admin" {
+				pages.SwitchToPage("main")
+			} else {
+				adminView.SetText(formatAdminMessages(processor))
+				adminView.ScrollToBeginning()
+				pages.SwitchToPage("admin")
+				app.SetFocus(adminView)
+			}
+		case 'y':
+			if front, _ := pages.GetFrontPage(); front == "symbols" {
+				pages.SwitchToPage("main")
+			} else {
+				pages.SwitchToPage("symbols")
+			}
+		case 'o':
+			if front, _ := pages.GetFrontPage(); front == "pointing" {
+				pages.SwitchToPage("main")
+			} else {
+				pages.SwitchToPage("pointing")
+			}
 		}
 		return event
 	})
+	// A signal cancels ctx without going through the TUI, so stop it from here
+	go func() {
+		<-ctx.Done()
+		app.Stop()
+	}()
+
 	//Update all data in our UI.
 	go func() {
-		for {
-			if !pause {
-				// Gather stats from decoder
-				decoder.StatsMutex.RLock()
-				frameLock := decoder.FrameLock
-				ber := decoder.Viterbi.GetPercentBER()
-				sigquality := decoder.SigQuality
-				RSCorrectionPercent := decoder.AverageRsCorrections
-				packetsPerChannel := decoder.RxPacketsPerChannel
-				droppedPacketsPerChannel := decoder.DroppedPacketsPerChannel
-				totalFrames := decoder.TotalFramesProcessed
+		for ctx.Err() == nil {
+			// Both snapshots are immutable, so they can be read without any locking
+			decoderStats := decoder.Stats()
+			demodStats := demodulator.Stats()
+
+			// The history keeps going while the TUI is paused
+			sample := historySample{
+				Time:      time.Now(),
+				SNR:       demodStats.SNR,
+				Quality:   float64(decoderStats.SigQuality),
+				BER:       float64(decoderStats.ViterbiPercentBER),
+				FrameLock: decoderStats.FrameLock,
+			}
+			signalHistory.add(sample)
+			dishPointing.add(sample)
 
+			if !pause {
 				// Update channel stats
-				var totalPacketsDropped int
-				for idx := 0; idx < len(channels); idx++ {
-					channel := ReadChannelData(idx)
-					channel.NumPackets = packetsPerChannel[channel.ID]
-					channel.NumPacketsDropped = droppedPacketsPerChannel[channel.ID]
-					WriteChannelData(idx, channel)
-					totalPacketsDropped += channel.NumPacketsDropped
-				}
-				decoder.StatsMutex.RUnlock()
+				UpdateChannels(decoderStats.Channels)
 
 				//Update gauges
-				signalGauge.SetValue(float64(sigquality))
-				berGauge.SetValue(float64(ber))
-				rsCorrectionsGauge.SetValue(float64(RSCorrectionPercent))
-
-				//Update signal plot data and SNR
-				demodulator.FFTMutex.RLock()
-
-				//TODO: Figure out how to update the SNR values outside of the update loop
-				fft := demodulator.CurrentFFT
-				snr := demodulator.CurrentSNR
-				snravg := demodulator.AvgSNR
-				snrpeak := demodulator.PeakSNR
-				demodulator.FFTMutex.RUnlock()
+				signalGauge.SetValue(float64(decoderStats.SigQuality))
+				berGauge.SetValue(float64(decoderStats.ViterbiPercentBER))
+				rsCorrectionsGauge.SetValue(float64(decoderStats.AverageRsCorrections))
 
 				//Update decoder stats
 				WriteOverallDecoderStats(DecoderStats{
-					FrameLock:           frameLock,
-					TotalPackets:        totalFrames,
-					TotalDroppedPackets: totalPacketsDropped,
-					SNR:                 snr,
-					AvgSNR:              snravg,
-					PeakSNR:             snrpeak,
+					FrameLock:           decoderStats.FrameLock,
+					TotalPackets:        decoderStats.TotalFrames,
+					TotalDroppedPackets: decoderStats.TotalDropped(),
+					SNR:                 demodStats.SNR,
+					SNREstimator:        demodStats.SNREstimator,
+					CN0:                 demodStats.CN0,
+					SNRWindows:          demodStats.SNRWindows,
+					PeakSNR:             demodStats.PeakSNR,
+					PhaseInverted:       decoderStats.PhaseInverted,
+					PhaseFlips:          decoderStats.PhaseFlips,
+					AGCGain:             demodStats.AGCGain,
+					ClockMu:             demodStats.ClockMu,
+					ClockOmega:          demodStats.ClockOmega,
 				})
 
+				if len(lookAngles) > 0 || rotator != nil {
+					header.SetText(formatHeader(lookAngles, rotator))
+				}
+				pointing := formatPointing(dishPointing, demodStats)
+				if rotator != nil {
+					pointing += "\n" + formatRotator(rotator.Latest())
+				}
+				pointingView.SetText(pointing)
+				symbolsView.SetText(formatQueueStats(r, demodulator, decoder) + formatPipelineStats(demodStats.Stages) + formatSymbolStats(demodStats.Symbols))
+
+				if demodStats.DCBlock || demodStats.IQCorrection {
+					signalPlot.SetTitle(spectrumTitle(demodStats))
+				}
+
+				fft := demodStats.FFT
 				if len(fft) > 0 {
 					var bins []float64
 					for _, val := range fft {
@@ -272,7 +363,149 @@ func StartUI(decoder *datalink.Decoder, demodulator *demod.Demodulator, r *radio
 	}()
 
 	// Start the TUI
-	if err := app.SetRoot(page, true).EnableMouse(true).Run(); err != nil {
+	if err := app.SetRoot(pages, true).EnableMouse(true).Run(); err != nil {
 		log.Fatalf("Could not start UI: %v", err)
 	}
+	// The log pane is gone, so anything logged while shutting down goes to the terminal
+	log.SetOutput(os.Stdout)
+}
+
+func channelStatsTitle(by ChannelSort) string {
+	return fmt.Sprintf("Per-Channel Stats (sorted by %s, 's' to change)", by)
+}
+
+// spectrumTitle lists the front end corrections being applied to the spectrum, and how big they are
+func spectrumTitle(stats *demod.DemodulatorStats) string {
+	title := "Signal"
+	if stats.DCBlock {
+		title += fmt.Sprintf(" | DC: %.4f%+.4fi", real(stats.DCOffset), imag(stats.DCOffset))
+	}
+	if stats.IQCorrection {
+		title += fmt.Sprintf(" | IQ: %.2f dB, %.2f°", stats.IQAmplitude, stats.IQPhase)
+	}
+	return title
+}
+
+// formatHeader shows the look angles and the rotator's position across the top of the main page
+func formatHeader(lookAngles []antenna.LookAngle, rotator *antenna.Rotator) string {
+	var text []string
+	if len(lookAngles) > 0 {
+		text = append(text, formatLookAngles(lookAngles))
+	}
+	if rotator != nil {
+		text = append(text, formatRotator(rotator.Latest()))
+	}
+	return " " + strings.Join(text, "  |  ")
+}
+
+// formatRotator shows where the rotator is pointing, or why we don't know
+func formatRotator(position *antenna.RotatorPosition) string {
+	switch {
+	case position == nil:
+		return "[lightskyblue]Rotator:[white] connecting..."
+	case position.Time.IsZero():
+		return "[lightskyblue]Rotator:[red] not responding"
+	case position.Err != nil:
+		return fmt.Sprintf("[lightskyblue]Rotator:[red] az %.1f°, el %.1f° (not responding)", position.Azimuth, position.Elevation)
+	}
+	return fmt.Sprintf("[lightskyblue]Rotator:[white] az %.1f°, el %.1f° (arrow keys to jog)", position.Azimuth, position.Elevation)
+}
+
+// formatLookAngles lists where to point the dish at each satellite that's above the horizon
+func formatLookAngles(lookAngles []antenna.LookAngle) string {
+	var text []string
+	for _, angle := range lookAngles {
+		if angle.Visible() {
+			text = append(text, fmt.Sprintf("[lightskyblue]%s:[white] az %.1f°, el %.1f°, skew %+.1f°", angle.Satellite.Name, angle.Azimuth, angle.Elevation, angle.Skew))
+		}
+	}
+	if len(text) == 0 {
+		return "[red]None of the satellites are above the horizon from here"
+	}
+	return strings.Join(text, "  |  ")
+}
+
+// formatAdminMessages renders the admin messages we've received, newest first
+func formatAdminMessages(processor *products.Processor) string {
+	if processor == nil {
+		return "[red]Product decoding is disabled; set products.enabled = true to receive admin messages"
+	}
+
+	messages := processor.Admin.Messages()
+	if len(messages) == 0 {
+		return "No admin messages received yet"
+	}
+
+	var text string
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		text += fmt.Sprintf("[lightskyblue]%s [white]%s\n%s\n\n", msg.Time.UTC().Format("2006-01-02 15:04:05 UTC"), tview.Escape(msg.Name), tview.Escape(msg.Text))
+	}
+	return text
+}
+
+// formatQueueStats shows how full each of the queues between the pipeline's stages is, along with how many
+// blocks have been dropped, or held up the stage sending them, because the next stage was behind
+func formatQueueStats(r *radio.Radio[complex64], demodulator *demod.Demodulator, decoder *datalink.Decoder) string {
+	text := "[white::b]Queues[-::-]\n"
+	text += fmt.Sprintf("[lightskyblue]%-26s %9s %10s %9s %9s\n", "", "Depth", "Sent", "Dropped", "Stalls")
+	row := func(name string, depth int, capacity int, stats *queue.Stats) {
+		dropColor := "white"
+		if stats.Dropped() > 0 {
+			dropColor = "red"
+		}
+		text += fmt.Sprintf("[lightskyblue]%-26s [white]%9s %10d [%s]%9d [white]%9d\n", name, fmt.Sprintf("%d/%d", depth, capacity), stats.Sent(), dropColor, stats.Dropped(), stats.Stalls())
+	}
+
+	row("Radio → Demodulator", len(demodulator.SampleInput), cap(demodulator.SampleInput), &r.OutputStats)
+	row("Demodulator → Decoder", len(decoder.SymbolsInput), cap(decoder.SymbolsInput), &demodulator.OutputStats)
+	for _, output := range decoder.FramesOutputs {
+		row("Decoder → "+output.Name, len(*output.Frames), cap(*output.Frames), &output.Stats)
+	}
+	return text + "\n"
+}
+
+// formatPipelineStats lists each of the demodulator's stages in order, along with their stats
+func formatPipelineStats(stages []demod.StageStats) string {
+	text := "[white::b]Demodulator[-::-]\n"
+	for i, stage := range stages {
+		text += fmt.Sprintf("[lightskyblue]%2d. %-14s[white]", i+1, stage.Stage)
+		for _, stat := range stage.Stats {
+			text += fmt.Sprintf(" %s: %.4g ", stat.Name, stat.Value)
+		}
+		text += "\n"
+	}
+	return text + "\n[white::b]Soft Symbols[-::-]\n"
+}
+
+// formatSymbolStats renders the stats and histogram of the last block of soft symbols
+func formatSymbolStats(stats demod.SymbolStats) string {
+	const barWidth = 50
+
+	clipColor := "green"
+	if stats.ClipRate > 5 {
+		clipColor = "red"
+	} else if stats.ClipRate > 1 {
+		clipColor = "yellow"
+	}
+
+	text := fmt.Sprintf("[lightskyblue]Scale:               [white]%.1f\n", stats.Scale)
+	text += fmt.Sprintf("[lightskyblue]Mean Magnitude:      [white]%.3f\n", stats.MeanMagnitude)
+	text += fmt.Sprintf("[lightskyblue]Mean Soft Magnitude: [white]%.1f / 128\n", stats.MeanSoftMagnitude)
+	text += fmt.Sprintf("[lightskyblue]Clipping Rate:       [%s]%.2f%%\n\n", clipColor, stats.ClipRate)
+
+	// Two clear humps sitting inside the range, with little piled up at either end, is what we want
+	var peak int
+	for _, count := range stats.Histogram {
+		peak = max(peak, count)
+	}
+	binWidth := 256 / demod.SymbolHistogramBins
+	for i, count := range stats.Histogram {
+		width := 0
+		if peak > 0 {
+			width = count * barWidth / peak
+		}
+		text += fmt.Sprintf("[lightskyblue]%3d-%3d [white]%s %d\n", i*binWidth, (i+1)*binWidth-1, strings.Repeat("█", width), count)
+	}
+	return text
 }
@@ -2,10 +2,14 @@ package tui
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/jrwynneiii/goestuner/datalink"
+	"github.com/jrwynneiii/goestuner/demod"
 	"github.com/rivo/tview"
 )
 
@@ -22,6 +26,9 @@ type Channel struct {
 	Name              string
 	NumPackets        int
 	NumPacketsDropped int
+	FramesPerSecond   float64
+	Bytes             int64
+	LastSeen          time.Time
 }
 
 type DecoderStats struct {
@@ -29,13 +36,18 @@ type DecoderStats struct {
 	TotalPackets        int
 	TotalDroppedPackets int
 	SNR                 float64
-	AvgSNR              float64
-	PeakSNR             float64
+	SNREstimator        string
+	CN0                 float64
+	SNRWindows          []demod.SNRWindowStats
+	PeakSNR             demod.PeakHold
+	PhaseInverted       bool
+	PhaseFlips          int
+	AGCGain             float32
+	ClockMu             float32
+	ClockOmega          float32
 }
 
-var overallDecoderStats = DecoderStats{
-	false, 0, 0, 0.0, 0.0, 0.0,
-}
+var overallDecoderStats = DecoderStats{}
 
 var DecoderStatsMutex sync.RWMutex
 
@@ -52,83 +64,203 @@ func WriteOverallDecoderStats(d DecoderStats) {
 	overallDecoderStats = d
 }
 
+// ChannelSort is the order the per-channel stats table is shown in
+type ChannelSort int
+
+const (
+	SortByID ChannelSort = iota
+	SortByPackets
+	SortByDropRate
+)
+
+func (s ChannelSort) String() string {
+	switch s {
+	case SortByPackets:
+		return "packets"
+	case SortByDropRate:
+		return "drop rate"
+	default:
+		return "ID"
+	}
+}
+
+// Next cycles to the next sort order
+func (s ChannelSort) Next() ChannelSort {
+	return (s + 1) % 3
+}
+
+// DropRate is the percentage of frames on the channel that were dropped
+func (c Channel) DropRate() float64 {
+	total := c.NumPackets + c.NumPacketsDropped
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(c.NumPacketsDropped) / float64(total)
+}
+
+// lastSeen formats how long ago the channel's last good frame arrived
+func (c Channel) lastSeen() string {
+	if c.LastSeen.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%ds ago", int(time.Since(c.LastSeen).Seconds()))
+}
+
+// formatBytes formats a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func ResetChannelAndDecoderStats() {
-	WriteOverallDecoderStats(DecoderStats{false, 0, 0, 0.0, 0.0, 0.0})
-
-	channels = []Channel{{0, datalink.VCIDs[0], 0, 0},
-		{1, datalink.VCIDs[1], 0, 0},
-		{2, datalink.VCIDs[2], 0, 0},
-		{6, datalink.VCIDs[6], 0, 0},
-		{7, datalink.VCIDs[7], 0, 0},
-		{8, datalink.VCIDs[8], 0, 0},
-		{9, datalink.VCIDs[9], 0, 0},
-		{13, datalink.VCIDs[13], 0, 0},
-		{14, datalink.VCIDs[14], 0, 0},
-		{15, datalink.VCIDs[15], 0, 0},
-		{17, datalink.VCIDs[17], 0, 0},
-		{20, datalink.VCIDs[20], 0, 0},
-		{21, datalink.VCIDs[21], 0, 0},
-		{22, datalink.VCIDs[22], 0, 0},
-		{23, datalink.VCIDs[23], 0, 0},
-		{24, datalink.VCIDs[24], 0, 0},
-		{25, datalink.VCIDs[25], 0, 0},
-		{26, datalink.VCIDs[26], 0, 0},
-		{30, datalink.VCIDs[30], 0, 0},
-		{31, datalink.VCIDs[31], 0, 0},
-		{32, datalink.VCIDs[32], 0, 0},
-		{60, datalink.VCIDs[60], 0, 0},
-		{63, datalink.VCIDs[63], 0, 0}}
-}
-
-var channels = []Channel{
-	{0, datalink.VCIDs[0], 0, 0},
-	{1, datalink.VCIDs[1], 0, 0},
-	{2, datalink.VCIDs[2], 0, 0},
-	{6, datalink.VCIDs[6], 0, 0},
-	{7, datalink.VCIDs[7], 0, 0},
-	{8, datalink.VCIDs[8], 0, 0},
-	{9, datalink.VCIDs[9], 0, 0},
-	{13, datalink.VCIDs[13], 0, 0},
-	{14, datalink.VCIDs[14], 0, 0},
-	{15, datalink.VCIDs[15], 0, 0},
-	{17, datalink.VCIDs[17], 0, 0},
-	{20, datalink.VCIDs[20], 0, 0},
-	{21, datalink.VCIDs[21], 0, 0},
-	{22, datalink.VCIDs[22], 0, 0},
-	{23, datalink.VCIDs[23], 0, 0},
-	{24, datalink.VCIDs[24], 0, 0},
-	{25, datalink.VCIDs[25], 0, 0},
-	{26, datalink.VCIDs[26], 0, 0},
-	{30, datalink.VCIDs[30], 0, 0},
-	{31, datalink.VCIDs[31], 0, 0},
-	{32, datalink.VCIDs[32], 0, 0},
-	{60, datalink.VCIDs[60], 0, 0},
-	{63, datalink.VCIDs[63], 0, 0},
+	WriteOverallDecoderStats(DecoderStats{})
+	UpdateChannels(nil)
 }
 
+var channels []Channel
+var channelSort = SortByID
+
 var channelsMutex sync.RWMutex
 
-func WriteChannelData(idx int, c Channel) {
+// UpdateChannels rebuilds the per-channel table from a snapshot of the decoder's channel stats. Every
+// channel we have a name for is listed, along with any other VCID we've actually seen frames on
+func UpdateChannels(stats map[int]datalink.ChannelStats) {
+	ids := make(map[int]bool)
+	for id := range datalink.VCIDs {
+		ids[id] = true
+	}
+	for id := range stats {
+		ids[id] = true
+	}
+
+	updated := make([]Channel, 0, len(ids))
+	for id := range ids {
+		s := stats[id]
+		updated = append(updated, Channel{
+			ID:                id,
+			Name:              datalink.VCIDName(id),
+			NumPackets:        s.Received,
+			NumPacketsDropped: s.Dropped,
+			FramesPerSecond:   s.FramesPerSecond,
+			Bytes:             s.Bytes,
+			LastSeen:          s.LastSeen,
+		})
+	}
+
 	channelsMutex.Lock()
 	defer channelsMutex.Unlock()
-	channels[idx] = c
+	sortChannels(updated, channelSort)
+	channels = updated
+}
+
+func sortChannels(c []Channel, by ChannelSort) {
+	sort.Slice(c, func(i, j int) bool {
+		switch by {
+		case SortByPackets:
+			if c[i].NumPackets != c[j].NumPackets {
+				return c[i].NumPackets > c[j].NumPackets
+			}
+		case SortByDropRate:
+			if c[i].DropRate() != c[j].DropRate() {
+				return c[i].DropRate() > c[j].DropRate()
+			}
+		}
+		return c[i].ID < c[j].ID
+	})
+}
+
+// CycleChannelSort switches the table to the next sort order, and returns it
+func CycleChannelSort() ChannelSort {
+	channelsMutex.Lock()
+	defer channelsMutex.Unlock()
+	channelSort = channelSort.Next()
+	sortChannels(channels, channelSort)
+	return channelSort
+}
+
+func ReadChannelData(idx int) (Channel, bool) {
+	channelsMutex.RLock()
+	defer channelsMutex.RUnlock()
+	if idx < 0 || idx >= len(channels) {
+		return Channel{}, false
+	}
+	return channels[idx], true
 }
 
-func ReadChannelData(idx int) Channel {
+func channelCount() int {
 	channelsMutex.RLock()
 	defer channelsMutex.RUnlock()
-	return channels[idx]
+	return len(channels)
 }
 
 func (l *LockTableData) GetRowCount() int {
-	return 6
+	// One row for each SNR window
+	return 11 + len(ReadOverallDecoderStats().SNRWindows)
 }
 
 func (l *LockTableData) GetColumnCount() int {
 	return 2
 }
 
+// snrColor picks the colour an SNR is shown in
+func snrColor(snr float64) string {
+	if snr < 1.0 {
+		return "[red]"
+	}
+	return "[green]"
+}
+
+// formatWindow formats an SNR window's length, e.g. "5s" or "1m"
+func formatWindow(window time.Duration) string {
+	switch {
+	case window == 0:
+		return "session"
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return window.String()
+	}
+}
+
+// formatSNRWindow formats the SNR over a window as its mean and standard deviation, and range
+func formatSNRWindow(stats demod.SNRWindowStats) string {
+	if stats.Count == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s%.2f ± %.2f [white](%.2f to %.2f)", snrColor(stats.Mean), stats.Mean, stats.StdDev, stats.Min, stats.Max)
+}
+
+// formatPeakSNR formats the best SNR seen and how long ago, along with the decaying peak hold
+func formatPeakSNR(peak demod.PeakHold) string {
+	if peak.Time.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%s%.2f [white](%ds ago, holding %.2f)", snrColor(peak.Peak), peak.Peak, int(time.Since(peak.Time).Seconds()), peak.Held)
+}
+
 func (l *LockTableData) GetCell(row, column int) *tview.TableCell {
+	// The SNR windows sit after the C/N0, and push the rows after them down
+	windows := ReadOverallDecoderStats().SNRWindows
+	if row >= 5 && row < 5+len(windows) {
+		if column == 0 {
+			return tview.NewTableCell(fmt.Sprintf("SNR (%s):", formatWindow(windows[row-5].Window)))
+		}
+		return tview.NewTableCell(formatSNRWindow(windows[row-5]))
+	}
+	if row >= 5+len(windows) {
+		row -= len(windows)
+	}
+
 	switch row {
 	case 0:
 		if column == 0 {
@@ -154,74 +286,103 @@ func (l *LockTableData) GetCell(row, column int) *tview.TableCell {
 		return tview.NewTableCell(fmt.Sprintf("%d", ReadOverallDecoderStats().TotalDroppedPackets))
 	case 3:
 		if column == 0 {
-			return tview.NewTableCell("SNR:")
+			return tview.NewTableCell(fmt.Sprintf("SNR (Es/N0, %s):", ReadOverallDecoderStats().SNREstimator))
 		}
 
 		snr := ReadOverallDecoderStats().SNR
-		color := ""
-		if snr < 1.0 {
-			color = "[red]"
-		} else {
-			color = "[green]"
+		if math.IsNaN(snr) {
+			return tview.NewTableCell("[red]-")
 		}
-
-		return tview.NewTableCell(fmt.Sprintf("%s%f", color, snr))
+		return tview.NewTableCell(fmt.Sprintf("%s%.2f dB", snrColor(snr), snr))
 	case 4:
 		if column == 0 {
-			return tview.NewTableCell("Average SNR:")
+			return tview.NewTableCell("C/N0:")
 		}
 
-		snr := ReadOverallDecoderStats().AvgSNR
-		color := ""
-		if snr < 1.0 {
-			color = "[red]"
-		} else {
-			color = "[green]"
+		cn0 := ReadOverallDecoderStats().CN0
+		if math.IsNaN(cn0) {
+			return tview.NewTableCell("-")
 		}
-
-		return tview.NewTableCell(fmt.Sprintf("%s%f", color, snr))
+		return tview.NewTableCell(fmt.Sprintf("%.2f dB-Hz", cn0))
 	case 5:
 		if column == 0 {
 			return tview.NewTableCell("Peak SNR:")
 		}
 
-		snr := ReadOverallDecoderStats().PeakSNR
-		color := ""
-		if snr < 1.0 {
-			color = "[red]"
-		} else {
-			color = "[green]"
+		return tview.NewTableCell(formatPeakSNR(ReadOverallDecoderStats().PeakSNR))
+	case 6:
+		if column == 0 {
+			return tview.NewTableCell("Phase:")
+		}
+
+		if ReadOverallDecoderStats().PhaseInverted {
+			return tview.NewTableCell("[yellow]180° (inverted)")
+		}
+		return tview.NewTableCell("[green]0°")
+	case 7:
+		if column == 0 {
+			return tview.NewTableCell("Phase Flips:")
+		}
+
+		return tview.NewTableCell(fmt.Sprintf("%d", ReadOverallDecoderStats().PhaseFlips))
+	case 8:
+		if column == 0 {
+			return tview.NewTableCell("AGC Gain:")
 		}
 
-		return tview.NewTableCell(fmt.Sprintf("%s%f", color, snr))
+		return tview.NewTableCell(fmt.Sprintf("%f", ReadOverallDecoderStats().AGCGain))
+	case 9:
+		if column == 0 {
+			return tview.NewTableCell("Clock Mu:")
+		}
+
+		return tview.NewTableCell(fmt.Sprintf("%f", ReadOverallDecoderStats().ClockMu))
+	case 10:
+		if column == 0 {
+			return tview.NewTableCell("Clock Omega:")
+		}
+
+		return tview.NewTableCell(fmt.Sprintf("%f", ReadOverallDecoderStats().ClockOmega))
 	default:
 		return tview.NewTableCell("ERROR")
 	}
-	return tview.NewTableCell("ERROR")
 }
 
 func (d *ChannelTableData) GetRowCount() int {
-	return len(channels)
+	// Channels, plus the header row
+	return channelCount() + 1
 }
 
 func (d *ChannelTableData) GetColumnCount() int {
-	return 4
+	return 8
 }
 
 func (c *ChannelTableData) GetCell(row, column int) *tview.TableCell {
 	if row != 0 {
+		channel, ok := ReadChannelData(row - 1)
+		if !ok {
+			return nil
+		}
 		switch column {
 		case 0:
-			return tview.NewTableCell(fmt.Sprintf("[lightskyblue]%d", ReadChannelData(row).ID))
+			return tview.NewTableCell(fmt.Sprintf("[lightskyblue]%d", channel.ID))
 		case 1:
-			return tview.NewTableCell(fmt.Sprintf("[white]%s", ReadChannelData(row).Name))
+			return tview.NewTableCell(fmt.Sprintf("[white]%s", channel.Name))
 		case 2:
-			if ReadChannelData(row).NumPackets == 0 {
-				return tview.NewTableCell(fmt.Sprintf("[red]%d", ReadChannelData(row).NumPackets))
+			if channel.NumPackets == 0 {
+				return tview.NewTableCell(fmt.Sprintf("[red]%d", channel.NumPackets))
 			}
-			return tview.NewTableCell(fmt.Sprintf("[green]%d", ReadChannelData(row).NumPackets))
+			return tview.NewTableCell(fmt.Sprintf("[green]%d", channel.NumPackets))
 		case 3:
-			return tview.NewTableCell(fmt.Sprintf("[red]%d", ReadChannelData(row).NumPacketsDropped))
+			return tview.NewTableCell(fmt.Sprintf("[red]%d", channel.NumPacketsDropped))
+		case 4:
+			return tview.NewTableCell(fmt.Sprintf("[red]%.1f%%", channel.DropRate()))
+		case 5:
+			return tview.NewTableCell(fmt.Sprintf("[white]%.2f", channel.FramesPerSecond))
+		case 6:
+			return tview.NewTableCell(fmt.Sprintf("[white]%s", formatBytes(channel.Bytes)))
+		case 7:
+			return tview.NewTableCell(fmt.Sprintf("[white]%s", channel.lastSeen()))
 		}
 	} else {
 		switch column {
@@ -232,7 +393,15 @@ func (c *ChannelTableData) GetCell(row, column int) *tview.TableCell {
 		case 2:
 			return tview.NewTableCell("[green]Packets RX'd ")
 		case 3:
-			return tview.NewTableCell("[red]Packets Dropped")
+			return tview.NewTableCell("[red]Packets Dropped ")
+		case 4:
+			return tview.NewTableCell("[red]Drop % ")
+		case 5:
+			return tview.NewTableCell("[white]Frames/s ")
+		case 6:
+			return tview.NewTableCell("[white]Bytes RX'd ")
+		case 7:
+			return tview.NewTableCell("[white]Last Seen")
 		}
 
 	}